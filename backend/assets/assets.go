@@ -0,0 +1,176 @@
+// Package assets stores files belonging to articles, such as their images,
+// on disk. Files are addressed by the SHA-256 hash of their content, so a file
+// shared by several articles is stored once and never changes.
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// URLPrefix is the path assets are served under.
+const URLPrefix = "/api/assets/"
+
+// ErrInvalidHash is returned for names that are not asset hashes.
+var ErrInvalidHash = errors.New("invalid asset hash")
+
+// hashPattern matches a hex-encoded SHA-256 hash.
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var (
+	dirMu sync.RWMutex
+	dir   string
+)
+
+var (
+	// refMu orders storing assets against removing unused ones, and guards
+	// pending.
+	refMu sync.Mutex
+	// pending counts the times Put stored each asset without Release being
+	// called yet, while the references to it are being saved.
+	pending = make(map[string]int)
+)
+
+// SetDir sets the directory assets are stored in.
+func SetDir(d string) {
+	dirMu.Lock()
+	defer dirMu.Unlock()
+	dir = d
+}
+
+// IsHash reports whether name is a valid asset hash.
+func IsHash(name string) bool {
+	return hashPattern.MatchString(name)
+}
+
+// URL returns the path an asset is served at.
+func URL(hash string) string {
+	return URLPrefix + hash
+}
+
+// Path returns the file an asset is stored in. Files are spread over
+// subdirectories named after the first two characters of their hash.
+func Path(hash string) (string, error) {
+	if !IsHash(hash) {
+		return "", ErrInvalidHash
+	}
+
+	dirMu.RLock()
+	defer dirMu.RUnlock()
+	if dir == "" {
+		return "", errors.New("asset directory not set")
+	}
+	return filepath.Join(dir, hash[:2], hash), nil
+}
+
+// Put stores data and returns its hash. Data that is already stored is not
+// written again. The asset is not removed by RemoveUnused until Release is
+// called with its hash, which callers do once the references to it are saved.
+func Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	path, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+
+	refMu.Lock()
+	pending[hash]++
+	refMu.Unlock()
+
+	if err := write(path, data); err != nil {
+		Release(hash)
+		return "", err
+	}
+	return hash, nil
+}
+
+// Release lets an asset stored by Put be removed again.
+func Release(hash string) {
+	refMu.Lock()
+	defer refMu.Unlock()
+	if pending[hash]--; pending[hash] <= 0 {
+		delete(pending, hash)
+	}
+}
+
+// write stores data in the file at path unless it exists.
+func write(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial asset
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Open opens a stored asset for reading.
+func Open(hash string) (*os.File, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Read returns the content of a stored asset.
+func Read(hash string) ([]byte, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// RemoveUnused deletes a stored asset unless it is being stored by Put or
+// inUse reports that something still refers to it. It reports whether the
+// asset was removed; removing an asset that does not exist is not an error.
+func RemoveUnused(hash string, inUse func() (bool, error)) (bool, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return false, err
+	}
+
+	// Holding the lock until the file is gone keeps Put from finding it
+	// between the check and the removal
+	refMu.Lock()
+	defer refMu.Unlock()
+	if pending[hash] > 0 {
+		return false, nil
+	}
+	if used, err := inUse(); err != nil || used {
+		return false, err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
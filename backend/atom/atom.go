@@ -0,0 +1,140 @@
+// Package atom defines Atom feed documents (RFC 4287) and the OPDS 1.2
+// extensions used by the e-reader catalog.
+package atom
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	// Namespace is the Atom XML namespace.
+	Namespace = "http://www.w3.org/2005/Atom"
+	// DCNamespace is the Dublin Core namespace OPDS uses for metadata.
+	DCNamespace = "http://purl.org/dc/terms/"
+	// OPDSNamespace is the OPDS catalog namespace.
+	OPDSNamespace = "http://opds-spec.org/2010/catalog"
+	// ThreadNamespace is the Atom threading extension namespace, whose count
+	// attribute tells OPDS clients how many entries a link leads to.
+	ThreadNamespace = "http://purl.org/syndication/thread/1.0"
+)
+
+// Media types of Atom feeds and OPDS catalog feeds.
+const (
+	ContentType         = "application/atom+xml; charset=utf-8"
+	NavigationFeedType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionFeedType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// Link relations.
+const (
+	RelSelf        = "self"
+	RelStart       = "start"
+	RelUp          = "up"
+	RelNext        = "next"
+	RelAlternate   = "alternate"
+	RelSubsection  = "subsection"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+	RelSortNew     = "http://opds-spec.org/sort/new"
+)
+
+// Feed is an Atom feed document.
+type Feed struct {
+	XMLName   xml.Name `xml:"feed"`
+	XMLNS     string   `xml:"xmlns,attr"`
+	XMLNSDC   string   `xml:"xmlns:dc,attr,omitempty"`
+	XMLNSOPDS string   `xml:"xmlns:opds,attr,omitempty"`
+	XMLNSThr  string   `xml:"xmlns:thr,attr,omitempty"`
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Subtitle  string   `xml:"subtitle,omitempty"`
+	Updated   Time     `xml:"updated"`
+	Author    *Person  `xml:"author,omitempty"`
+	Icon      string   `xml:"icon,omitempty"`
+	Links     []Link   `xml:"link"`
+	Entries   []Entry  `xml:"entry"`
+}
+
+// Entry is an entry of an Atom feed.
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    Time       `xml:"updated"`
+	Published  *Time      `xml:"published,omitempty"`
+	Authors    []Person   `xml:"author,omitempty"`
+	Language   string     `xml:"dc:language,omitempty"`
+	Issued     string     `xml:"dc:issued,omitempty"`
+	Source     string     `xml:"dc:source,omitempty"`
+	Categories []Category `xml:"category,omitempty"`
+	Summary    *Text      `xml:"summary,omitempty"`
+	Content    *Text      `xml:"content,omitempty"`
+	Links      []Link     `xml:"link"`
+}
+
+// Person is the author of a feed or entry.
+type Person struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+// Link is a link of a feed or entry.
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	// Count is the number of entries behind an OPDS navigation link.
+	Count int `xml:"thr:count,attr,omitempty"`
+}
+
+// Category is a category, such as a tag, of an entry.
+type Category struct {
+	Term   string `xml:"term,attr"`
+	Label  string `xml:"label,attr,omitempty"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+}
+
+// Text is a text construct. Type is "text", "html" or "xhtml".
+type Text struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+// Time is a timestamp formatted as RFC 3339, as required by Atom.
+type Time time.Time
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Format(time.RFC3339)), nil
+}
+
+// NewFeed returns a feed with the Atom namespace and the namespaces of the
+// OPDS extensions declared.
+func NewFeed(id, title string, updated time.Time) *Feed {
+	return &Feed{
+		XMLNS:     Namespace,
+		XMLNSDC:   DCNamespace,
+		XMLNSOPDS: OPDSNamespace,
+		XMLNSThr:  ThreadNamespace,
+		ID:        id,
+		Title:     title,
+		Updated:   Time(updated),
+	}
+}
+
+// Write writes the feed as an XML document.
+func (f *Feed) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
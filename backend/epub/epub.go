@@ -0,0 +1,405 @@
+// Package epub builds EPUB 3 books from article HTML. Books include a
+// navigation document and an NCX table of contents for older readers, embed
+// the images of their chapters, and use stylesheets suited to CJK text.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// maxImageSize is the largest image embedded in a book.
+	maxImageSize = 10 << 20
+	// maxBookImageSize caps the total size of the images embedded in a book.
+	// Images past it are replaced with their alt text.
+	maxBookImageSize = 200 << 20
+)
+
+// imageTypes maps the image media types allowed in EPUB 3 to file extensions.
+var imageTypes = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// ImageFetcher downloads an image referenced by a chapter and returns its
+// data and media type.
+type ImageFetcher func(src string) ([]byte, string, error)
+
+// Chapter is one article of a book.
+type Chapter struct {
+	Title   string
+	Source  string    // URL of the original article
+	Site    string    // 来源网站，显示在章节标题下
+	Date    time.Time // 保存时间
+	HTML    string    // Sanitized article HTML
+	Text    string    // Plain text, used when there is no HTML
+	Authors []string
+}
+
+// Book is an EPUB 3 book under construction.
+type Book struct {
+	Title     string
+	Authors   []string
+	Publisher string
+	Sources   []string // dc:source entries, such as the URL of a single article
+	Modified  time.Time
+
+	id         string
+	fetch      ImageFetcher
+	chapters   []chapterFile
+	images     []imageFile
+	imageHrefs map[string]string
+	imageBytes int
+	language   languageCounter
+}
+
+// chapterFile and imageFile export their fields to the templates.
+type chapterFile struct {
+	ID    string
+	Href  string
+	Title string
+	body  []byte
+}
+
+type imageFile struct {
+	ID        string
+	Href      string
+	MediaType string
+	data      []byte
+}
+
+// New returns an empty book. fetch is used to download the images of its
+// chapters; images it fails to fetch are replaced with their alt text.
+func New(title string, fetch ImageFetcher) *Book {
+	return &Book{
+		Title:      title,
+		Publisher:  "Read It Later",
+		Modified:   time.Now(),
+		id:         newUUID(),
+		fetch:      fetch,
+		imageHrefs: make(map[string]string),
+	}
+}
+
+// Len returns the number of chapters in the book.
+func (b *Book) Len() int {
+	return len(b.chapters)
+}
+
+// AddChapter converts a chapter to XHTML, embedding its images, and appends
+// it to the book.
+func (b *Book) AddChapter(ch Chapter) error {
+	var content bytes.Buffer
+	if strings.TrimSpace(ch.HTML) != "" {
+		if err := b.renderHTML(&content, ch.HTML); err != nil {
+			return err
+		}
+	} else {
+		for _, line := range strings.Split(ch.Text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				content.WriteString("<p>")
+				content.WriteString(html.EscapeString(line))
+				content.WriteString("</p>\n")
+			}
+		}
+	}
+
+	b.language.add(ch.Title)
+	b.language.add(ch.Text)
+	b.language.add(ch.HTML)
+
+	n := len(b.chapters) + 1
+	chapter := chapterFile{
+		ID:    fmt.Sprintf("chapter-%d", n),
+		Href:  fmt.Sprintf("text/chapter-%d.xhtml", n),
+		Title: ch.Title,
+	}
+
+	var page bytes.Buffer
+	err := chapterTemplate.Execute(&page, map[string]interface{}{
+		"Title":   ch.Title,
+		"Source":  ch.Source,
+		"Site":    ch.Site,
+		"Date":    formatDate(ch.Date),
+		"Authors": strings.Join(ch.Authors, ", "),
+		"Content": content.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	chapter.body = page.Bytes()
+	b.chapters = append(b.chapters, chapter)
+	return nil
+}
+
+// renderHTML writes article HTML as XHTML, replacing image sources with the
+// embedded copies.
+func (b *Book) renderHTML(w io.Writer, content string) error {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+	b.embedImages(body)
+
+	for node := body.FirstChild; node != nil; node = node.NextSibling {
+		// The HTML renderer closes void elements, so its output is valid XHTML
+		if err := html.Render(w, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedImages points the images under node to embedded copies, replacing
+// those that cannot be fetched with their alt text.
+func (b *Book) embedImages(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && child.Data == "img" {
+			src, alt := attr(child, "src"), attr(child, "alt")
+			if href := b.embedImage(src); href != "" {
+				setAttr(child, "src", "../"+href)
+				if alt == "" {
+					// EPUB requires alt text, which may be empty
+					setAttr(child, "alt", "")
+				}
+			} else if alt != "" {
+				node.InsertBefore(&html.Node{Type: html.TextNode, Data: "[" + alt + "]"}, child)
+				node.RemoveChild(child)
+			} else {
+				node.RemoveChild(child)
+			}
+		} else {
+			b.embedImages(child)
+		}
+		child = next
+	}
+}
+
+// embedImage adds an image to the book, once per source, and returns its
+// path in the book. It returns "" if the image cannot be embedded.
+func (b *Book) embedImage(src string) string {
+	if src == "" || b.fetch == nil {
+		return ""
+	}
+	if href, ok := b.imageHrefs[src]; ok {
+		return href
+	}
+	b.imageHrefs[src] = ""
+
+	data, mediaType, err := b.fetch(src)
+	if err != nil {
+		log.Printf("Error fetching image %s for EPUB: %v", src, err)
+		return ""
+	}
+
+	mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+	ext, ok := imageTypes[mediaType]
+	if !ok || len(data) > maxImageSize || b.imageBytes+len(data) > maxBookImageSize {
+		return ""
+	}
+
+	n := len(b.images) + 1
+	image := imageFile{
+		ID:        fmt.Sprintf("image-%d", n),
+		Href:      fmt.Sprintf("images/image-%d%s", n, ext),
+		MediaType: mediaType,
+		data:      data,
+	}
+	b.images = append(b.images, image)
+	b.imageBytes += len(data)
+	b.imageHrefs[src] = image.Href
+	return image.Href
+}
+
+// Write writes the book as an EPUB file.
+func (b *Book) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	// The mimetype file must come first and be stored uncompressed
+	mimetype := []byte("application/epub+zip")
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(mimetype),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	}
+	mw, err := z.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(mimetype); err != nil {
+		return err
+	}
+
+	language := b.language.language()
+	data := map[string]interface{}{
+		"Book":     b,
+		"ID":       b.id,
+		"Language": language,
+		"Modified": b.Modified.UTC().Format("2006-01-02T15:04:05Z"),
+		"Chapters": b.chapters,
+		"Images":   b.images,
+	}
+
+	files := []struct {
+		name     string
+		template *template.Template
+	}{
+		{"META-INF/container.xml", containerTemplate},
+		{"OEBPS/content.opf", packageTemplate},
+		{"OEBPS/nav.xhtml", navTemplate},
+		{"OEBPS/toc.ncx", ncxTemplate},
+		{"OEBPS/style.css", styleTemplate},
+	}
+	for _, file := range files {
+		fw, err := b.create(z, file.name)
+		if err != nil {
+			return err
+		}
+		if err := file.template.Execute(fw, data); err != nil {
+			return err
+		}
+	}
+
+	for _, chapter := range b.chapters {
+		// Chapters inherit the language detected for the whole book
+		body := bytes.Replace(chapter.body, []byte(`xml:lang=""`), []byte(`xml:lang="`+language+`"`), 1)
+		body = bytes.Replace(body, []byte(`lang=""`), []byte(`lang="`+language+`"`), 1)
+		if err := b.writeFile(z, path.Join("OEBPS", chapter.Href), body); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range b.images {
+		if err := b.writeFile(z, path.Join("OEBPS", image.Href), image.data); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+// create adds a compressed file to the archive and returns a writer for its
+// contents.
+func (b *Book) create(z *zip.Writer, name string) (io.Writer, error) {
+	return z.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: b.Modified,
+	})
+}
+
+// writeFile adds a compressed file to the archive.
+func (b *Book) writeFile(z *zip.Writer, name string, data []byte) error {
+	fw, err := b.create(z, name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// languageCounter guesses the main language of a book from the scripts used
+// in its text, so readers pick suitable fonts and line breaking.
+type languageCounter struct {
+	han, kana, hangul, other int
+}
+
+// add counts the letters of text.
+func (lc *languageCounter) add(text string) {
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			lc.kana++
+		case unicode.Is(unicode.Han, r):
+			lc.han++
+		case unicode.Is(unicode.Hangul, r):
+			lc.hangul++
+		case unicode.IsLetter(r):
+			lc.other++
+		}
+	}
+}
+
+// language returns the BCP 47 tag of the dominant script.
+func (lc *languageCounter) language() string {
+	// Latin letters are counted per character, while a single CJK character
+	// often carries a whole word, so CJK text wins with far fewer characters
+	cjk := lc.han + lc.kana + lc.hangul
+	if cjk*4 < lc.other {
+		return "en"
+	}
+	switch {
+	case lc.kana > 0 && lc.kana*10 >= cjk:
+		return "ja"
+	case lc.hangul > lc.han:
+		return "ko"
+	default:
+		return "zh"
+	}
+}
+
+// formatDate formats a chapter date, or returns "" for the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// attr returns the value of an attribute of node.
+func attr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets an attribute of node.
+func setAttr(node *html.Node, name string, value string) {
+	for i, a := range node.Attr {
+		if a.Key == name {
+			node.Attr[i].Val = value
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: name, Val: value})
+}
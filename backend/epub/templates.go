@@ -0,0 +1,245 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"text/template"
+)
+
+// templateFuncs are available in every template. Values are escaped with xml
+// explicitly, since text/template does not escape anything by itself.
+var templateFuncs = template.FuncMap{
+	"xml": func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	},
+	"inc": func(i int) int { return i + 1 },
+}
+
+func newTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(templateFuncs).Parse(text))
+}
+
+var containerTemplate = newTemplate("container.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+
+// packageTemplate is the OPF package document. ibooks:specified-fonts makes
+// Apple Books honour the fonts of the stylesheet.
+var packageTemplate = newTemplate("content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Language}}" prefix="ibooks: http://vocabulary.itunes.apple.com/rdf/ibooks/vocabulary-extensions-1.0/">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">urn:uuid:{{.ID}}</dc:identifier>
+    <dc:title>{{xml .Book.Title}}</dc:title>
+    <dc:language>{{.Language}}</dc:language>
+{{- range .Book.Authors}}
+    <dc:creator>{{xml .}}</dc:creator>
+{{- end}}
+{{- if .Book.Publisher}}
+    <dc:publisher>{{xml .Book.Publisher}}</dc:publisher>
+{{- end}}
+{{- range .Book.Sources}}
+    <dc:source>{{xml .}}</dc:source>
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta property="ibooks:specified-fonts">true</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range .Images}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="{{.MediaType}}"/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="nav" linear="no"/>
+{{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>
+`)
+
+var navTemplate = newTemplate("nav.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+<head>
+  <meta charset="utf-8"/>
+  <title>{{xml .Book.Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{xml .Book.Title}}</h1>
+    <ol>
+{{- range .Chapters}}
+      <li><a href="{{.Href}}">{{xml .Title}}</a></li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`)
+
+// ncxTemplate is the EPUB 2 table of contents, for readers that do not
+// support navigation documents.
+var ncxTemplate = newTemplate("toc.ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1" xml:lang="{{.Language}}">
+  <head>
+    <meta name="dtb:uid" content="urn:uuid:{{.ID}}"/>
+    <meta name="dtb:depth" content="1"/>
+    <meta name="dtb:totalPageCount" content="0"/>
+    <meta name="dtb:maxPageNumber" content="0"/>
+  </head>
+  <docTitle><text>{{xml .Book.Title}}</text></docTitle>
+  <navMap>
+{{- range $i, $chapter := .Chapters}}
+    <navPoint id="nav-{{$chapter.ID}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{xml $chapter.Title}}</text></navLabel>
+      <content src="{{$chapter.Href}}"/>
+    </navPoint>
+{{- end}}
+  </navMap>
+</ncx>
+`)
+
+// chapterTemplate renders one article. The language attributes are left
+// empty and filled in once the language of the whole book is known.
+var chapterTemplate = newTemplate("chapter.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="" lang="">
+<head>
+  <meta charset="utf-8"/>
+  <title>{{xml .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body>
+  <section epub:type="chapter">
+    <h1 class="chapter-title">{{xml .Title}}</h1>
+    <p class="chapter-meta">
+{{- if .Authors}}<span class="author">{{xml .Authors}}</span> · {{end -}}
+{{- if .Source}}<a href="{{xml .Source}}">{{if .Site}}{{xml .Site}}{{else}}{{xml .Source}}{{end}}</a>{{end -}}
+{{- if .Date}} · <time>{{.Date}}</time>{{end -}}
+    </p>
+    {{.Content}}
+  </section>
+</body>
+</html>
+`)
+
+// styleTemplate is the book stylesheet. The font stacks list common Chinese,
+// Japanese and Korean serif fonts, and strict line breaking keeps CJK
+// punctuation from starting a line.
+var styleTemplate = newTemplate("style.css", `@charset "UTF-8";
+@namespace epub "http://www.idpf.org/2007/ops";
+
+html {
+  -epub-line-break: strict;
+  line-break: strict;
+  -webkit-line-break: strict;
+}
+
+body {
+  font-family: serif;
+  line-height: 1.7;
+  text-align: justify;
+  margin: 0 0.5em;
+}
+
+:lang(zh) body, body:lang(zh) {
+  font-family: "Noto Serif CJK SC", "Source Han Serif SC", "Songti SC", "STSong", "SimSun", serif;
+  -epub-hyphens: none;
+  hyphens: none;
+}
+
+:lang(ja) body, body:lang(ja) {
+  font-family: "Noto Serif CJK JP", "Source Han Serif JP", "Hiragino Mincho ProN", "YuMincho", serif;
+  -epub-hyphens: none;
+  hyphens: none;
+}
+
+:lang(ko) body, body:lang(ko) {
+  font-family: "Noto Serif CJK KR", "Source Han Serif KR", "AppleMyungjo", "Batang", serif;
+  word-break: keep-all;
+}
+
+:lang(en) body, body:lang(en) {
+  -epub-hyphens: auto;
+  hyphens: auto;
+}
+
+h1, h2, h3, h4, h5, h6 {
+  line-height: 1.4;
+  text-align: left;
+  page-break-after: avoid;
+}
+
+.chapter-title {
+  margin-bottom: 0.3em;
+}
+
+.chapter-meta {
+  font-size: 0.8em;
+  color: #666;
+  margin-bottom: 2em;
+}
+
+p {
+  margin: 0 0 0.8em;
+}
+
+img {
+  max-width: 100%;
+  height: auto;
+}
+
+figure {
+  margin: 1em 0;
+  text-align: center;
+}
+
+figcaption {
+  font-size: 0.85em;
+  color: #666;
+}
+
+blockquote {
+  margin: 1em 1.5em;
+  color: #555;
+}
+
+pre, code, kbd, samp {
+  font-family: "Source Code Pro", "Menlo", "Consolas", monospace;
+  font-size: 0.9em;
+}
+
+pre {
+  white-space: pre-wrap;
+  word-wrap: break-word;
+  background: #f5f5f5;
+  padding: 0.5em;
+}
+
+table {
+  border-collapse: collapse;
+  margin: 1em 0;
+}
+
+th, td {
+  border: 1px solid #999;
+  padding: 0.2em 0.4em;
+}
+
+a {
+  color: inherit;
+}
+`)
@@ -0,0 +1,96 @@
+// Package events delivers per-user notifications, such as extraction
+// progress and tag changes, to connected Server-Sent Events clients.
+package events
+
+import (
+	"read-it-later/backend/model"
+	"sync"
+)
+
+// Event types.
+const (
+	ArticleExtracting = "article.extracting"
+	ArticleExtracted  = "article.extracted"
+	ArticleFailed     = "article.failed"
+	ArticleDeleted    = "article.deleted"
+	TagAdded          = "tag.added"
+	TagRemoved        = "tag.removed"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber. Events
+// for a subscriber that falls this far behind are dropped.
+const subscriberBuffer = 32
+
+// Event is a notification sent to a user's clients.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// JobStarted is the data of an article.extracting event.
+type JobStarted struct {
+	ArticleID int `json:"article_id"`
+	JobID     int `json:"job_id"`
+	Attempt   int `json:"attempt"`
+}
+
+// JobFailure is the data of an article.failed event.
+type JobFailure struct {
+	ArticleID int    `json:"article_id"`
+	JobID     int    `json:"job_id"`
+	Attempt   int    `json:"attempt"`
+	Error     string `json:"error"`
+	WillRetry bool   `json:"will_retry"` // 是否会自动重试
+}
+
+// TagChange is the data of tag.added and tag.removed events.
+type TagChange struct {
+	ArticleID int       `json:"article_id"`
+	Tag       model.Tag `json:"tag"`
+}
+
+// ArticleRef is the data of events that only identify an article.
+type ArticleRef struct {
+	ArticleID int `json:"article_id"`
+}
+
+var (
+	mu          sync.Mutex
+	subscribers = make(map[int]map[chan Event]struct{})
+)
+
+// Subscribe registers a client for a user's events. The returned function
+// must be called to unregister it.
+func Subscribe(userID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	mu.Lock()
+	if subscribers[userID] == nil {
+		subscribers[userID] = make(map[chan Event]struct{})
+	}
+	subscribers[userID][ch] = struct{}{}
+	mu.Unlock()
+
+	return ch, func() {
+		mu.Lock()
+		delete(subscribers[userID], ch)
+		if len(subscribers[userID]) == 0 {
+			delete(subscribers, userID)
+		}
+		mu.Unlock()
+	}
+}
+
+// Publish sends an event to all of a user's connected clients without
+// blocking on slow ones.
+func Publish(userID int, eventType string, data interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for ch := range subscribers[userID] {
+		select {
+		case ch <- Event{Type: eventType, Data: data}:
+		default:
+		}
+	}
+}
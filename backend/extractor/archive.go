@@ -0,0 +1,164 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"read-it-later/backend/warc"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// recorderKey is the context key of the recording set by WithRecorder.
+type recorderKey struct{}
+
+// recording is the context value set by WithRecorder.
+type recording struct {
+	recorder *warc.Recorder
+	browser  bool
+}
+
+// WithRecorder returns a context that makes extraction record the HTTP
+// requests it makes and their responses in recorder. If browser is true, the
+// network traffic of the headless browser is recorded too, through the
+// DevTools protocol.
+func WithRecorder(ctx context.Context, recorder *warc.Recorder, browser bool) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recording{recorder: recorder, browser: browser})
+}
+
+// httpClient returns the client pages are fetched with, recording its
+// traffic if the context asks for it.
+func httpClient(ctx context.Context) *http.Client {
+	client := &http.Client{Timeout: 15 * time.Second}
+	if r, ok := ctx.Value(recorderKey{}).(recording); ok {
+		client.Transport = r.recorder.Transport(http.DefaultTransport)
+	}
+	return client
+}
+
+// browserExchange is a request made by the headless browser, collected from
+// network events.
+type browserExchange struct {
+	request  *network.Request
+	response *network.Response
+	date     time.Time
+	finished bool
+}
+
+// recordBrowserTraffic starts collecting the network traffic of a browser
+// context if the context asks for it. The returned function reads the
+// bodies of the finished responses and adds the exchanges to the recorder;
+// it must be called while the browser is still running.
+func recordBrowserTraffic(browserCtx context.Context) func() {
+	r, ok := browserCtx.Value(recorderKey{}).(recording)
+	if !ok || !r.browser {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	exchanges := make(map[network.RequestID]*browserExchange)
+	var order []network.RequestID
+	var redirects []*browserExchange
+
+	chromedp.ListenTarget(browserCtx, func(ev interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if previous, ok := exchanges[ev.RequestID]; ok && ev.RedirectResponse != nil {
+				// Redirects reuse the request ID of the request they answer
+				previous.response = ev.RedirectResponse
+				previous.finished = true
+				redirects = append(redirects, previous)
+			} else if !ok {
+				order = append(order, ev.RequestID)
+			}
+			exchanges[ev.RequestID] = &browserExchange{request: ev.Request, date: time.Now()}
+		case *network.EventResponseReceived:
+			if exchange, ok := exchanges[ev.RequestID]; ok {
+				exchange.response = ev.Response
+			}
+		case *network.EventLoadingFinished:
+			if exchange, ok := exchanges[ev.RequestID]; ok {
+				exchange.finished = true
+			}
+		}
+	})
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, exchange := range redirects {
+			addBrowserExchange(r.recorder, exchange, nil)
+		}
+		for _, id := range order {
+			exchange := exchanges[id]
+			if !exchange.finished || exchange.response == nil {
+				continue
+			}
+
+			var body []byte
+			err := chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				body, err = network.GetResponseBody(id).Do(ctx)
+				return err
+			}))
+			if err != nil || len(body) > warc.MaxRecordedSize {
+				continue
+			}
+			addBrowserExchange(r.recorder, exchange, body)
+		}
+	}
+}
+
+// addBrowserExchange adds a request made by the browser to a recorder. The
+// browser hands out decoded bodies, so the headers describing the transfer
+// encoding are replaced to match.
+func addBrowserExchange(recorder *warc.Recorder, exchange *browserExchange, body []byte) {
+	target := exchange.request.URL
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return
+	}
+
+	requestHeader := browserHeader(exchange.request.Headers)
+	if exchange.response.RequestHeaders != nil {
+		requestHeader = browserHeader(exchange.response.RequestHeaders)
+	}
+
+	responseHeader := browserHeader(exchange.response.Headers)
+	responseHeader.Del("Content-Encoding")
+	responseHeader.Del("Transfer-Encoding")
+	responseHeader.Set("Content-Length", fmt.Sprint(len(body)))
+
+	recorder.Add(warc.Exchange{
+		URL:            target,
+		Date:           exchange.date,
+		IPAddress:      strings.Trim(exchange.response.RemoteIPAddress, "[]"),
+		Request:        warc.RequestMessage(exchange.request.Method, target, requestHeader),
+		ResponseHeader: warc.ResponseHeader(int(exchange.response.Status), exchange.response.StatusText, responseHeader),
+		Body:           body,
+	})
+}
+
+// browserHeader converts headers reported by the browser. Fields with several
+// values are reported joined by newlines, and HTTP/2 pseudo-headers are
+// dropped.
+func browserHeader(headers network.Headers) http.Header {
+	header := http.Header{}
+	for name, value := range headers {
+		if strings.HasPrefix(name, ":") {
+			continue
+		}
+		for _, v := range strings.Split(fmt.Sprint(value), "\n") {
+			header.Add(name, v)
+		}
+	}
+	return header
+}
@@ -0,0 +1,299 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// captureTimeout bounds rendering and capturing a page.
+	captureTimeout = 90 * time.Second
+	// maxCaptureSize is the largest capture kept, before compression.
+	maxCaptureSize = 50 << 20
+
+	// viewportWidth and viewportHeight are the size of the window pages are
+	// rendered in.
+	viewportWidth  = 1280
+	viewportHeight = 800
+	// thumbnailWidth is the width thumbnails of the viewport are scaled to.
+	thumbnailWidth = 400
+	// screenshotQuality is the JPEG quality of screenshots and thumbnails.
+	screenshotQuality = 80
+)
+
+// CaptureOptions selects the forms a page is captured in.
+type CaptureOptions struct {
+	// Snapshot captures the page as a single HTML file.
+	Snapshot bool
+	// PDF prints the page to a PDF document.
+	PDF bool
+	// Screenshot captures the full page as an image, together with a
+	// thumbnail of the part shown first.
+	Screenshot bool
+}
+
+// Capture holds the captured forms of a page. Forms that were not requested
+// are nil.
+type Capture struct {
+	// Snapshot is a self-contained HTML document with stylesheets and images
+	// inlined as data URIs and scripts removed.
+	Snapshot []byte
+	// PDF is the page printed as a PDF document, with backgrounds.
+	PDF []byte
+	// Screenshot is a JPEG image of the full page.
+	Screenshot []byte
+	// Thumbnail is a small JPEG image of the top of the page.
+	Thumbnail []byte
+}
+
+// CapturePage renders a page in a headless browser and captures it in the
+// requested forms.
+func CapturePage(urlString string, opts CaptureOptions) (Capture, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), captureTimeout)
+	defer cancel()
+
+	browserCtx, cancel := newBrowserContext(ctx)
+	defer cancel()
+
+	err := chromedp.Run(browserCtx,
+		chromedp.EmulateViewport(viewportWidth, viewportHeight),
+		chromedp.Navigate(urlString),
+		chromedp.Sleep(3*time.Second),
+		// Scroll through the page so lazy-loaded images are fetched
+		chromedp.Evaluate(`window.scrollTo(0, document.body ? document.body.scrollHeight : 0)`, nil),
+		chromedp.Sleep(2*time.Second),
+		chromedp.Evaluate(`window.scrollTo(0, 0)`, nil),
+	)
+	if err != nil {
+		return Capture{}, fmt.Errorf("headless browser not available or failed: %v", err)
+	}
+
+	var capture Capture
+	if opts.Screenshot {
+		err := chromedp.Run(browserCtx,
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				capture.Thumbnail, err = page.CaptureScreenshot().
+					WithFormat(page.CaptureScreenshotFormatJpeg).
+					WithQuality(screenshotQuality).
+					WithClip(&page.Viewport{
+						Width:  viewportWidth,
+						Height: viewportHeight,
+						Scale:  float64(thumbnailWidth) / viewportWidth,
+					}).
+					Do(ctx)
+				return err
+			}),
+			chromedp.FullScreenshot(&capture.Screenshot, screenshotQuality),
+		)
+		if err != nil {
+			return Capture{}, fmt.Errorf("capturing screenshot: %v", err)
+		}
+		if len(capture.Screenshot) > maxCaptureSize {
+			return Capture{}, fmt.Errorf("screenshot larger than %d bytes", maxCaptureSize)
+		}
+	}
+
+	if opts.PDF {
+		err := chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			capture.PDF, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return err
+		}))
+		if err != nil {
+			return Capture{}, fmt.Errorf("printing PDF: %v", err)
+		}
+		if len(capture.PDF) > maxCaptureSize {
+			return Capture{}, fmt.Errorf("PDF larger than %d bytes", maxCaptureSize)
+		}
+	}
+
+	if opts.Snapshot {
+		var snapshot string
+		err := chromedp.Run(browserCtx, chromedp.Evaluate(snapshotScript, &snapshot, awaitPromise))
+		if err != nil {
+			return Capture{}, fmt.Errorf("capturing snapshot: %v", err)
+		}
+		if len(snapshot) > maxCaptureSize {
+			return Capture{}, fmt.Errorf("snapshot larger than %d bytes", maxCaptureSize)
+		}
+
+		header := fmt.Sprintf("<!DOCTYPE html>\n<!-- Saved from %s at %s -->\n",
+			strings.ReplaceAll(urlString, "--", "%2D%2D"), time.Now().UTC().Format(time.RFC3339))
+		capture.Snapshot = []byte(header + snapshot)
+	}
+
+	return capture, nil
+}
+
+// awaitPromise makes chromedp.Evaluate wait for the promise returned by a
+// script and use its value.
+func awaitPromise(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+	return p.WithAwaitPromise(true)
+}
+
+// snapshotScript serializes the rendered page as a self-contained document.
+// Stylesheets, including cross-origin ones, which the browser can read
+// because web security is disabled, are inlined as style elements, and
+// images and the resources stylesheets refer to become data URIs. Scripts,
+// event handlers, frames and embeds are removed.
+const snapshotScript = `
+(async () => {
+	const maxResourceSize = 10 * 1024 * 1024;
+	const lazyAttrs = ['data-src', 'data-original', 'data-actualsrc', 'data-lazy-src'];
+
+	const cache = new Map();
+	const toDataURL = (url) => {
+		if (!url || url.startsWith('data:')) {
+			return Promise.resolve(url || null);
+		}
+		if (!cache.has(url)) {
+			cache.set(url, (async () => {
+				try {
+					const response = await fetch(url);
+					if (!response.ok) return null;
+					const blob = await response.blob();
+					if (blob.size > maxResourceSize) return null;
+					return await new Promise((resolve) => {
+						const reader = new FileReader();
+						reader.onload = () => resolve(reader.result);
+						reader.onerror = () => resolve(null);
+						reader.readAsDataURL(blob);
+					});
+				} catch (e) {
+					return null;
+				}
+			})());
+		}
+		return cache.get(url);
+	};
+
+	const absolute = (url, base) => {
+		try {
+			return new URL(url, base).href;
+		} catch (e) {
+			return null;
+		}
+	};
+
+	const inlineCSS = async (css, base) => {
+		const pattern = /url\(\s*(['"]?)([^'")]+)\1\s*\)/g;
+		const refs = new Set();
+		let match;
+		while ((match = pattern.exec(css)) !== null) {
+			if (!match[2].startsWith('data:')) refs.add(match[2]);
+		}
+		for (const ref of refs) {
+			const data = await toDataURL(absolute(ref, base));
+			if (data) css = css.split(ref).join(data);
+		}
+		return css;
+	};
+
+	const sheetCSS = (sheet) => {
+		let rules;
+		try {
+			rules = Array.from(sheet.cssRules);
+		} catch (e) {
+			return null;
+		}
+		return rules.map((rule) => {
+			if (rule instanceof CSSImportRule && rule.styleSheet) {
+				return sheetCSS(rule.styleSheet) || '';
+			}
+			return rule.cssText;
+		}).join('\n');
+	};
+
+	// Read stylesheets and images from the live page, where the browser has
+	// parsed and loaded them, marking their elements to find them in the copy
+	const styles = [];
+	Array.from(document.styleSheets).forEach((sheet, i) => {
+		if (!sheet.ownerNode) return;
+		const css = sheetCSS(sheet);
+		if (css === null) return;
+		sheet.ownerNode.setAttribute('data-snapshot-style', i);
+		styles[i] = { css: css, base: sheet.href || document.baseURI, media: sheet.media.mediaText };
+	});
+
+	const imageSources = [];
+	document.querySelectorAll('img').forEach((img, i) => {
+		let src = img.currentSrc || img.src;
+		if (!src || src.startsWith('data:image/gif') || src.startsWith('data:image/svg')) {
+			for (const attr of lazyAttrs) {
+				if (img.getAttribute(attr)) {
+					src = absolute(img.getAttribute(attr), document.baseURI);
+					break;
+				}
+			}
+		}
+		img.setAttribute('data-snapshot-image', i);
+		imageSources[i] = src;
+	});
+
+	const root = document.documentElement.cloneNode(true);
+
+	document.querySelectorAll('[data-snapshot-style]').forEach((el) => el.removeAttribute('data-snapshot-style'));
+	document.querySelectorAll('[data-snapshot-image]').forEach((el) => el.removeAttribute('data-snapshot-image'));
+
+	root.querySelectorAll('script, noscript, iframe, frame, frameset, object, embed, applet, base, template').forEach((el) => el.remove());
+	root.querySelectorAll('meta[http-equiv]').forEach((el) => {
+		const name = el.getAttribute('http-equiv').toLowerCase();
+		if (name === 'refresh' || name === 'content-security-policy') el.remove();
+	});
+
+	for (const el of Array.from(root.querySelectorAll('[data-snapshot-style]'))) {
+		const entry = styles[el.getAttribute('data-snapshot-style')];
+		const style = document.createElement('style');
+		if (entry.media) style.setAttribute('media', entry.media);
+		style.textContent = await inlineCSS(entry.css, entry.base);
+		el.replaceWith(style);
+	}
+	root.querySelectorAll('link').forEach((el) => el.remove());
+
+	for (const img of Array.from(root.querySelectorAll('img'))) {
+		const data = await toDataURL(imageSources[img.getAttribute('data-snapshot-image')]);
+		img.removeAttribute('data-snapshot-image');
+		img.removeAttribute('srcset');
+		img.removeAttribute('sizes');
+		img.removeAttribute('loading');
+		lazyAttrs.forEach((attr) => img.removeAttribute(attr));
+		if (data) {
+			img.setAttribute('src', data);
+		}
+	}
+	root.querySelectorAll('picture source').forEach((el) => el.remove());
+
+	for (const el of Array.from(root.querySelectorAll('[style]'))) {
+		const css = el.getAttribute('style');
+		if (css.includes('url(')) {
+			el.setAttribute('style', await inlineCSS(css, document.baseURI));
+		}
+	}
+
+	root.querySelectorAll('*').forEach((el) => {
+		for (const attr of Array.from(el.attributes)) {
+			if (attr.name.toLowerCase().startsWith('on')) {
+				el.removeAttribute(attr.name);
+			}
+		}
+	});
+	root.querySelectorAll('a[href]').forEach((a) => {
+		const href = a.getAttribute('href').trim();
+		if (href.toLowerCase().startsWith('javascript:')) {
+			a.setAttribute('href', '#');
+		} else if (!href.startsWith('#')) {
+			const url = absolute(href, document.baseURI);
+			if (url) a.setAttribute('href', url);
+		}
+	});
+
+	return root.outerHTML;
+})()
+`
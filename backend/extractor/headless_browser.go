@@ -3,6 +3,7 @@ package extractor
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -11,6 +12,47 @@ import (
 	"github.com/chromedp/chromedp"
 )
 
+// BrowserSiteExtractor is a SiteExtractor for sites that load their content
+// with JavaScript. It renders pages in a headless browser and reads the
+// article with a site-specific script.
+type BrowserSiteExtractor struct {
+	// Domains lists the domains handled by the extractor, including their subdomains.
+	Domains []string
+	// ContentScript is evaluated in the rendered page and returns an object
+	// with the article text and HTML, as {text, html}.
+	ContentScript string
+}
+
+// genericBrowserExtractor renders pages that no BrowserSiteExtractor matches
+// when the browser is forced.
+var genericBrowserExtractor = &BrowserSiteExtractor{ContentScript: genericContentScript}
+
+// Match implements SiteExtractor.
+func (e *BrowserSiteExtractor) Match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range e.Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract implements SiteExtractor. Pages whose title cannot be read are left
+// to the next extractor, as are all pages when the browser is unavailable.
+func (e *BrowserSiteExtractor) Extract(ctx context.Context, u *url.URL) (model.Article, error) {
+	browserExtractor := NewHeadlessBrowserExtractor()
+	article, err := browserExtractor.ExtractWithBrowser(ctx, u.String(), e.ContentScript)
+	if err != nil {
+		return model.Article{}, fmt.Errorf("%w: %v", ErrNoContent, err)
+	}
+	if article.Title == "" {
+		return model.Article{}, fmt.Errorf("%w: no title found when rendering %s", ErrNoContent, u)
+	}
+
+	return article, nil
+}
+
 // HeadlessBrowserExtractor uses Chrome headless browser to extract content
 type HeadlessBrowserExtractor struct {
 	timeout time.Duration
@@ -23,33 +65,24 @@ func NewHeadlessBrowserExtractor() *HeadlessBrowserExtractor {
 	}
 }
 
-// ExtractWithBrowser extracts content using headless Chrome
-func (hbe *HeadlessBrowserExtractor) ExtractWithBrowser(urlString string) (model.Article, error) {
+// ExtractWithBrowser extracts content using headless Chrome. contentScript is
+// evaluated in the rendered page and must return the article text and HTML,
+// as {text, html}.
+func (hbe *HeadlessBrowserExtractor) ExtractWithBrowser(ctx context.Context, urlString string, contentScript string) (model.Article, error) {
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), hbe.timeout)
+	ctx, cancel := context.WithTimeout(ctx, hbe.timeout)
 	defer cancel()
 
-	// Create Chrome options - try to find Chrome first
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("disable-extensions", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-web-security", true),
-		chromedp.Flag("disable-features", "VizDisplayCompositor"),
-		chromedp.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
-	)
-
-	// Create allocator context
-	allocCtx, cancel := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, cancel := newBrowserContext(ctx)
 	defer cancel()
 
-	// Create browser context
-	browserCtx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	finishRecording := recordBrowserTraffic(browserCtx)
 
-	var title, content, description, imageURL string
+	var title, description, imageURL string
+	var page struct {
+		Text string `json:"text"`
+		HTML string `json:"html"`
+	}
 
 	// Run browser tasks
 	err := chromedp.Run(browserCtx,
@@ -79,16 +112,18 @@ func (hbe *HeadlessBrowserExtractor) ExtractWithBrowser(urlString string) (model
 			metaImg ? metaImg.getAttribute('content') || '' : '';
 		`, &imageURL),
 
-		// Extract main content for different sites
-		hbe.extractMainContent(urlString, &content),
+		// Extract main content with the site's script
+		chromedp.Evaluate(contentScript, &page),
 	)
 
 	if err != nil {
 		// If browser fails, return a meaningful error
 		return model.Article{}, fmt.Errorf("headless browser not available or failed: %v", err)
 	}
+	finishRecording()
 
 	// Clean and process extracted data
+	content := page.Text
 	article := model.Article{
 		URL:      urlString,
 		Title:    hbe.cleanTitle(title),
@@ -96,14 +131,46 @@ func (hbe *HeadlessBrowserExtractor) ExtractWithBrowser(urlString string) (model
 		Excerpt:  hbe.createExcerpt(description, content),
 		ImageURL: ProcessImageURL(imageURL),
 	}
+	if base, err := url.Parse(urlString); err == nil {
+		article.ContentHTML = SanitizeHTML(page.HTML, base)
+	}
 
 	// Ensure we have meaningful content
 	if len(strings.TrimSpace(article.Content)) < 50 {
-		article.Content = fmt.Sprintf("通过浏览器访问成功，但提取的内容较少。\n\n页面标题：%s\n描述：%s\n\n这可能是一个需要特殊处理的页面类型。请点击查看原文获取完整内容。", article.Title, description)
+		return model.Article{}, fmt.Errorf("%w: browser found too little content", ErrNoContent)
 	}
 
 	return article, nil
-} // waitForContent waits for content to be loaded
+}
+
+// newBrowserContext starts a headless Chrome and returns a context to run
+// browser actions in. Cancelling the context shuts the browser down.
+func newBrowserContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	// Create Chrome options - try to find Chrome first
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("disable-extensions", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-web-security", true),
+		chromedp.Flag("disable-features", "VizDisplayCompositor"),
+		chromedp.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+	)
+
+	// Create allocator context
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+
+	// Create browser context
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+
+	return browserCtx, func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+}
+
+// waitForContent waits for content to be loaded
 func (hbe *HeadlessBrowserExtractor) waitForContent() chromedp.Action {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
 		// Try to wait for different content indicators
@@ -139,149 +206,48 @@ func (hbe *HeadlessBrowserExtractor) waitForContent() chromedp.Action {
 	})
 }
 
-// extractMainContent extracts main content based on the website
-func (hbe *HeadlessBrowserExtractor) extractMainContent(urlString string, content *string) chromedp.Action {
-	if strings.Contains(urlString, "mp.weixin.qq.com") {
-		return hbe.extractWechatContent(content)
-	} else if strings.Contains(urlString, "zhuanlan.zhihu.com") {
-		return hbe.extractZhihuContent(content)
-	} else {
-		return hbe.extractGenericContent(content)
-	}
-}
-
-// extractWechatContent extracts WeChat article content
-func (hbe *HeadlessBrowserExtractor) extractWechatContent(content *string) chromedp.Action {
-	return chromedp.Evaluate(`
-		function extractWechatContent() {
-			// Try multiple selectors for WeChat content
-			const selectors = [
-				'.rich_media_content',
-				'#js_content',
-				'.rich_media_area_primary .rich_media_content',
-				'[data-role="main"]'
-			];
-			
-			for (const selector of selectors) {
-				const element = document.querySelector(selector);
-				if (element) {
-					// Clean up the content
-					const clone = element.cloneNode(true);
-					
-					// Remove unwanted elements
-					const unwanted = clone.querySelectorAll('script, style, .rich_media_tool, .rich_media_meta, [data-role="bottom"]');
-					unwanted.forEach(el => el.remove());
-					
-					const text = clone.innerText || clone.textContent || '';
-					if (text.trim().length > 100) {
-						return text.trim();
-					}
-				}
+// genericContentScript reads the main content of a page using common
+// content selectors, falling back to the body text.
+const genericContentScript = `
+function extractGenericContent() {
+	// Try common content selectors
+	const selectors = [
+		'article',
+		'main',
+		'.content',
+		'.article-content',
+		'.post-content',
+		'.entry-content',
+		'[role="main"]'
+	];
+	
+	for (const selector of selectors) {
+		const element = document.querySelector(selector);
+		if (element) {
+			const text = element.innerText || element.textContent || '';
+			if (text.trim().length > 100) {
+				return { text: text.trim(), html: element.innerHTML };
 			}
-			
-			// Fallback: try to get any meaningful text
-			const body = document.querySelector('body');
-			if (body) {
-				const text = body.innerText || body.textContent || '';
-				return text.trim();
-			}
-			
-			return '';
-		}
-		
-		extractWechatContent();
-	`, content)
-}
-
-// extractZhihuContent extracts Zhihu article content
-func (hbe *HeadlessBrowserExtractor) extractZhihuContent(content *string) chromedp.Action {
-	return chromedp.Evaluate(`
-		function extractZhihuContent() {
-			// Try multiple selectors for Zhihu content
-			const selectors = [
-				'.Post-RichTextContainer',
-				'.RichText',
-				'.Post-content',
-				'.ArticleItem-content',
-				'[data-testid="article-content"]'
-			];
-			
-			for (const selector of selectors) {
-				const element = document.querySelector(selector);
-				if (element) {
-					// Clean up the content
-					const clone = element.cloneNode(true);
-					
-					// Remove unwanted elements
-					const unwanted = clone.querySelectorAll('script, style, .Post-NormalMain, .ContentItem-actions');
-					unwanted.forEach(el => el.remove());
-					
-					const text = clone.innerText || clone.textContent || '';
-					if (text.trim().length > 100) {
-						return text.trim();
-					}
-				}
-			}
-			
-			// Fallback: try article tag
-			const article = document.querySelector('article');
-			if (article) {
-				const text = article.innerText || article.textContent || '';
-				if (text.trim().length > 100) {
-					return text.trim();
-				}
-			}
-			
-			return '';
 		}
+	}
+	
+	// Fallback: get body text
+	const body = document.querySelector('body');
+	if (body) {
+		// Remove script and style tags
+		const clone = body.cloneNode(true);
+		const unwanted = clone.querySelectorAll('script, style, nav, header, footer, aside, .sidebar, .navigation');
+		unwanted.forEach(el => el.remove());
 		
-		extractZhihuContent();
-	`, content)
+		const text = clone.innerText || clone.textContent || '';
+		return { text: text.trim(), html: clone.innerHTML };
+	}
+	
+	return { text: '', html: '' };
 }
 
-// extractGenericContent extracts content using generic selectors
-func (hbe *HeadlessBrowserExtractor) extractGenericContent(content *string) chromedp.Action {
-	return chromedp.Evaluate(`
-		function extractGenericContent() {
-			// Try common content selectors
-			const selectors = [
-				'article',
-				'main',
-				'.content',
-				'.article-content',
-				'.post-content',
-				'.entry-content',
-				'[role="main"]'
-			];
-			
-			for (const selector of selectors) {
-				const element = document.querySelector(selector);
-				if (element) {
-					const text = element.innerText || element.textContent || '';
-					if (text.trim().length > 100) {
-						return text.trim();
-					}
-				}
-			}
-			
-			// Fallback: get body text
-			const body = document.querySelector('body');
-			if (body) {
-				// Remove script and style tags
-				const clone = body.cloneNode(true);
-				const unwanted = clone.querySelectorAll('script, style, nav, header, footer, aside, .sidebar, .navigation');
-				unwanted.forEach(el => el.remove());
-				
-				const text = clone.innerText || clone.textContent || '';
-				return text.trim();
-			}
-			
-			return '';
-		}
-		
-		extractGenericContent();
-	`, content)
-}
+extractGenericContent();
+`
 
 // extractFallbackContent extracts content from full HTML as fallback
 func (hbe *HeadlessBrowserExtractor) extractFallbackContent(htmlContent string) string {
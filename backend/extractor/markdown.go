@@ -0,0 +1,430 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockElements are rendered as Markdown blocks rather than inline text.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "center": true,
+	"dd": true, "details": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true, "ul": true,
+}
+
+// skippedElements are dropped together with their content.
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "head": true,
+	"iframe": true, "object": true, "embed": true, "form": true, "button": true,
+	"input": true, "select": true, "textarea": true, "svg": true,
+}
+
+var (
+	whitespaceRun = regexp.MustCompile(`\s+`)
+	// blockStart matches text that Markdown would read as the start of a
+	// heading, quote, bullet or rule when it begins a paragraph.
+	blockStart = regexp.MustCompile(`^(#|>|[-+] |=+$|-+$)`)
+	// orderedStart matches text that would start an ordered list.
+	orderedStart = regexp.MustCompile(`^(\d+)([.)] )`)
+	blankLineRun = regexp.MustCompile(`\n{3,}`)
+)
+
+// markdownBlock is one rendered block. Lists are marked so list items can
+// nest them without blank lines.
+type markdownBlock struct {
+	text string
+	list bool
+}
+
+// HTMLToMarkdown converts article HTML to Markdown, keeping headings, lists,
+// links, images, emphasis, code blocks, block quotes and tables. Elements
+// without a Markdown equivalent are reduced to their text.
+func HTMLToMarkdown(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return ""
+	}
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+
+	markdown := joinBlocks(renderBlocks(body), false)
+	return strings.TrimSpace(blankLineRun.ReplaceAllString(markdown, "\n\n")) + "\n"
+}
+
+// renderBlocks renders the children of a node as blocks. Runs of inline
+// children become paragraphs.
+func renderBlocks(node *html.Node) []markdownBlock {
+	var blocks []markdownBlock
+	var inline strings.Builder
+
+	flush := func() {
+		if text := paragraph(inline.String()); text != "" {
+			blocks = append(blocks, markdownBlock{text: text})
+		}
+		inline.Reset()
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && skippedElements[child.Data] {
+			continue
+		}
+		if child.Type == html.ElementNode && blockElements[child.Data] {
+			flush()
+			blocks = append(blocks, renderBlock(child)...)
+			continue
+		}
+		inline.WriteString(renderInline(child))
+	}
+	flush()
+
+	return blocks
+}
+
+// renderBlock renders a block element.
+func renderBlock(node *html.Node) []markdownBlock {
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(node.Data[1] - '0')
+		text := strings.ReplaceAll(paragraph(renderChildrenInline(node)), "\n", " ")
+		if text == "" {
+			return nil
+		}
+		return []markdownBlock{{text: strings.Repeat("#", level) + " " + text}}
+
+	case "hr":
+		return []markdownBlock{{text: "---"}}
+
+	case "pre":
+		return []markdownBlock{{text: codeBlock(node)}}
+
+	case "blockquote":
+		inner := joinBlocks(renderBlocks(node), false)
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return []markdownBlock{{text: strings.Join(lines, "\n")}}
+
+	case "ul", "ol":
+		if text := renderList(node); text != "" {
+			return []markdownBlock{{text: text, list: true}}
+		}
+		return nil
+
+	case "table":
+		if text := renderTable(node); text != "" {
+			return []markdownBlock{{text: text}}
+		}
+		return nil
+
+	case "dt":
+		text := paragraph(renderChildrenInline(node))
+		if text == "" {
+			return nil
+		}
+		return []markdownBlock{{text: "**" + text + "**"}}
+
+	default:
+		return renderBlocks(node)
+	}
+}
+
+// joinBlocks separates blocks with blank lines. Within list items, nested
+// lists directly follow the preceding text to keep the list tight.
+func joinBlocks(blocks []markdownBlock, inListItem bool) string {
+	var b strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if inListItem && block.list {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(block.text)
+	}
+	return b.String()
+}
+
+// renderList renders the items of a ul or ol element, indenting nested
+// content under each item's marker.
+func renderList(node *html.Node) string {
+	ordered := node.Data == "ol"
+	number := 1
+	if start, err := strconv.Atoi(dom.GetAttribute(node, "start")); err == nil {
+		number = start
+	}
+
+	var items []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		text := joinBlocks(renderBlocks(child), true)
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(text, "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// renderTable renders a table as a GitHub Flavored Markdown table, using the
+// first row as the header.
+func renderTable(table *html.Node) string {
+	var rows [][]string
+	columns := 0
+
+	var collect func(*html.Node)
+	collect = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "tr":
+				var cells []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := strings.Join(strings.Fields(renderChildrenInline(cell)), " ")
+						cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				rows = append(rows, cells)
+				columns = max(columns, len(cells))
+			default:
+				collect(child)
+			}
+		}
+	}
+	collect(table)
+
+	if len(rows) == 0 || columns == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// codeBlock renders a pre element as a fenced code block, taking the language
+// from a language-* class on the pre or its code element.
+func codeBlock(pre *html.Node) string {
+	language := codeLanguage(pre)
+	for child := pre.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			language = codeLanguage(child)
+		}
+	}
+
+	code := strings.TrimRight(dom.TextContent(pre), "\n")
+	code = strings.TrimPrefix(code, "\n")
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// codeLanguage returns the language named by a language-* class of node.
+func codeLanguage(node *html.Node) string {
+	for _, class := range strings.Fields(dom.GetAttribute(node, "class")) {
+		if language, found := strings.CutPrefix(class, "language-"); found {
+			return language
+		}
+	}
+	return ""
+}
+
+// renderChildrenInline renders all children of a node as inline text.
+func renderChildrenInline(node *html.Node) string {
+	var b strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(renderInline(child))
+	}
+	return b.String()
+}
+
+// renderInline renders a node as inline Markdown. Block elements nested in
+// inline ones are reduced to their inline content.
+func renderInline(node *html.Node) string {
+	switch node.Type {
+	case html.TextNode:
+		return escapeMarkdown(whitespaceRun.ReplaceAllString(node.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skippedElements[node.Data] {
+		return ""
+	}
+
+	switch node.Data {
+	case "br":
+		return "  \n"
+
+	case "strong", "b":
+		return wrapInline(renderChildrenInline(node), "**")
+
+	case "em", "i", "cite":
+		return wrapInline(renderChildrenInline(node), "*")
+
+	case "del", "s", "strike":
+		return wrapInline(renderChildrenInline(node), "~~")
+
+	case "code", "kbd", "samp":
+		code := whitespaceRun.ReplaceAllString(dom.TextContent(node), " ")
+		if strings.TrimSpace(code) == "" {
+			return code
+		}
+		fence := strings.Repeat("`", longestRun(code, '`')+1)
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		return fence + code + fence
+
+	case "a":
+		text := renderChildrenInline(node)
+		href := dom.GetAttribute(node, "href")
+		if strings.TrimSpace(text) == "" || href == "" || strings.HasPrefix(href, "#") {
+			return text
+		}
+		return "[" + strings.TrimSpace(text) + "](" + markdownURL(href) + markdownTitle(dom.GetAttribute(node, "title")) + ")"
+
+	case "img":
+		src := dom.GetAttribute(node, "src")
+		if src == "" {
+			return ""
+		}
+		alt := escapeMarkdown(strings.TrimSpace(dom.GetAttribute(node, "alt")))
+		return "![" + alt + "](" + markdownURL(src) + markdownTitle(dom.GetAttribute(node, "title")) + ")"
+
+	default:
+		return renderChildrenInline(node)
+	}
+}
+
+// wrapInline surrounds text with an emphasis marker, keeping surrounding
+// whitespace outside it so the marker is recognised.
+func wrapInline(text string, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:len(text)-len(strings.TrimLeft(text, " \n"))]
+	trailing := text[len(strings.TrimRight(text, " \n")):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+// paragraph tidies the inline content of a block: it trims the text and the
+// start of each line, and escapes text that would otherwise start a block.
+func paragraph(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+	text = strings.Join(lines, "\n")
+
+	if blockStart.MatchString(text) {
+		text = `\` + text
+	}
+	return orderedStart.ReplaceAllString(text, `$1\$2`)
+}
+
+// escapeMarkdown escapes characters in text that Markdown would treat as
+// formatting. Underscores inside words are left alone.
+func escapeMarkdown(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		switch r {
+		case '\\', '*', '`', '[', ']', '<':
+			b.WriteRune('\\')
+		case '_':
+			inWord := i > 0 && i < len(runes)-1 && isWordRune(runes[i-1]) && isWordRune(runes[i+1])
+			if !inWord {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isWordRune reports whether r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// markdownURL formats a link destination, wrapping it in angle brackets if
+// it contains characters that would end it early.
+func markdownURL(href string) string {
+	if strings.ContainsAny(href, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(href) + ">"
+	}
+	return href
+}
+
+// markdownTitle formats an optional link title.
+func markdownTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.ReplaceAll(title, `"`, `\"`) + `"`
+}
+
+// longestRun returns the length of the longest run of r in s.
+func longestRun(s string, r rune) int {
+	longest, current := 0, 0
+	for _, c := range s {
+		if c == r {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
@@ -1,83 +1,61 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"read-it-later/backend/model"
 
 	"github.com/go-shiori/go-readability"
 )
 
-// Extract fetches the content from a URL and uses go-readability to parse it
-// into a structured Article object.
-func Extract(urlString string) (model.Article, error) {
-	// Parse the URL string
-	parsedURL, err := url.ParseRequestURI(urlString)
-	if err != nil {
-		return model.Article{}, err
-	}
-
-	// Check if this is a Zhihu article and use headless browser
-	if strings.Contains(urlString, "zhihu.com") {
-		browserExtractor := NewHeadlessBrowserExtractor()
-		article, err := browserExtractor.ExtractWithBrowser(urlString)
-		if err == nil && article.Title != "" {
-			return article, nil
-		}
-		// If browser extraction fails, continue with regular extraction
-	}
+func init() {
+	Register(readabilityExtractor{}, PriorityReadability)
+	Register(fallbackExtractor{}, PriorityFallback)
+}
 
-	// Check if this is a WeChat article and use headless browser
-	if strings.Contains(urlString, "mp.weixin.qq.com") {
-		browserExtractor := NewHeadlessBrowserExtractor()
-		article, err := browserExtractor.ExtractWithBrowser(urlString)
-		if err == nil && article.Title != "" {
-			return article, nil
-		}
-		// If browser extraction fails, continue with regular extraction
-	}
+// readabilityExtractor fetches a page over HTTP and uses go-readability to
+// parse it. It matches every page.
+type readabilityExtractor struct{}
 
-	// Make HTTP request with better headers
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequest("GET", urlString, nil)
-	if err != nil {
-		return model.Article{}, err
-	}
+// Match implements SiteExtractor.
+func (readabilityExtractor) Match(u *url.URL) bool {
+	return true
+}
 
-	// Add user agent to avoid being blocked
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+// Extract implements SiteExtractor.
+func (readabilityExtractor) Extract(ctx context.Context, u *url.URL) (model.Article, error) {
+	urlString := u.String()
 
-	resp, err := client.Do(req)
+	resp, err := fetchPage(ctx, urlString, nil)
 	if err != nil {
 		return model.Article{}, err
 	}
 	defer resp.Body.Close()
 
 	// Use go-readability to parse the document
-	article, err := readability.FromReader(resp.Body, parsedURL)
+	article, err := readability.FromReader(resp.Body, u)
 	if err != nil {
 		return model.Article{}, err
 	}
 
 	// Check if extraction was successful (content is meaningful)
 	if isContentEmpty(article) {
-		// Try fallback method for problematic sites
-		return extractWithFallback(urlString, parsedURL)
+		// Let the fallback handle problematic sites
+		return model.Article{}, fmt.Errorf("%w: readability found too little content", ErrNoContent)
 	}
 
 	// Create our own Article model from the parsed data
 	result := model.Article{
-		URL:      urlString,
-		Title:    article.Title,
-		Content:  article.TextContent, // Using TextContent for a cleaner reading view
-		Excerpt:  article.Excerpt,
-		ImageURL: ProcessImageURL(article.Image),
+		URL:         urlString,
+		Title:       article.Title,
+		Content:     article.TextContent, // Using TextContent for a cleaner reading view
+		ContentHTML: SanitizeHTML(article.Content, u),
+		Excerpt:     article.Excerpt,
+		ImageURL:    ProcessImageURL(article.Image),
 	}
 
 	return result, nil
@@ -113,10 +91,52 @@ func isContentEmpty(article readability.Article) bool {
 	return false
 }
 
-// extractWithFallback attempts to extract content using alternative methods
-func extractWithFallback(urlString string, parsedURL *url.URL) (model.Article, error) {
+// fetchPage requests a page with browser-like headers. Entries in headers
+// replace the defaults. Error pages are reported as ErrNoContent rather than
+// extracted as articles.
+func fetchPage(ctx context.Context, urlString string, headers map[string]string) (*http.Response, error) {
+	// Make HTTP request with better headers
+	client := httpClient(ctx)
+	req, err := http.NewRequestWithContext(ctx, "GET", urlString, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add user agent to avoid being blocked
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrNoContent, resp.Status)
+	}
+	return resp, nil
+}
+
+// fallbackExtractor produces a placeholder pointing to the original page
+// when no other extractor found its content. It matches every page, but is
+// skipped when refreshing an article.
+type fallbackExtractor struct{}
+
+// Match implements SiteExtractor.
+func (fallbackExtractor) Match(u *url.URL) bool {
+	return true
+}
+
+// Extract implements SiteExtractor.
+func (fallbackExtractor) Extract(ctx context.Context, u *url.URL) (model.Article, error) {
+	urlString := u.String()
+
 	// Try to get at least the title from the URL or domain
-	title := getBasicTitle(parsedURL)
+	title := getBasicTitle(u)
 
 	result := model.Article{
 		URL:      urlString,
@@ -0,0 +1,146 @@
+package extractor
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// lazyImageAttrs are attributes sites use to hold the source of lazy-loaded
+// images until a script copies it into src.
+var lazyImageAttrs = []string{"data-src", "data-original", "data-actualsrc", "data-lazy-src"}
+
+// contentPolicy is the allowlist applied to article HTML. It keeps the
+// structure of the text, links, images, code and tables, and drops
+// everything else, including scripts, styles, forms, embeds and all id,
+// class and style attributes.
+var contentPolicy = newContentPolicy()
+
+func newContentPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"article", "section", "div", "span", "p", "br", "hr",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"blockquote", "pre", "code", "kbd", "samp",
+		"em", "strong", "b", "i", "u", "s", "del", "ins", "mark", "small", "sub", "sup", "abbr", "cite", "q",
+		"ul", "ol", "li", "dl", "dt", "dd",
+		"figure", "figcaption",
+		"table", "caption", "thead", "tbody", "tfoot", "tr", "th", "td",
+	)
+
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.AllowAttrs("width", "height").Matching(bluemonday.NumberOrPercent).OnElements("img")
+	p.AllowAttrs("colspan", "rowspan").Matching(bluemonday.Integer).OnElements("td", "th")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("title").OnElements("abbr")
+	// 保留代码块的语言标记，便于前端高亮
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+
+	// Relative URLs are only left for images served through the image proxy
+	p.AllowStandardURLs()
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// SanitizeHTML makes extracted article HTML safe to display. Relative links
+// and image sources are resolved against base, lazy-loaded images get their
+// real source, and everything not on the allowlist is removed.
+func SanitizeHTML(content string, base *url.URL) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	return strings.TrimSpace(contentPolicy.Sanitize(absolutizeURLs(content, base)))
+}
+
+// HTMLToText returns the text of sanitized article HTML, as stored for the
+// reading view, search and highlights.
+func HTMLToText(content string) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, node := range nodes {
+		b.WriteString(dom.TextContent(node))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// absolutizeURLs rewrites the link and image URLs of an HTML fragment to
+// absolute ones. Image sources are also passed through ProcessImageURL.
+func absolutizeURLs(content string, base *url.URL) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return content
+	}
+
+	var b strings.Builder
+	for _, node := range nodes {
+		rewriteURLs(node, base)
+		if err := html.Render(&b, node); err != nil {
+			return content
+		}
+	}
+	return b.String()
+}
+
+// rewriteURLs resolves the URLs of node and its descendants against base.
+func rewriteURLs(node *html.Node, base *url.URL) {
+	if node.Type == html.ElementNode {
+		switch node.Data {
+		case "a":
+			resolveAttr(node, "href", base)
+		case "img":
+			src := strings.TrimSpace(dom.GetAttribute(node, "src"))
+			if src == "" || strings.HasPrefix(src, "data:") {
+				for _, attr := range lazyImageAttrs {
+					if lazySrc := strings.TrimSpace(dom.GetAttribute(node, attr)); lazySrc != "" {
+						dom.SetAttribute(node, "src", lazySrc)
+						break
+					}
+				}
+			}
+			if resolveAttr(node, "src", base) {
+				dom.SetAttribute(node, "src", ProcessImageURL(dom.GetAttribute(node, "src")))
+			}
+		}
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		rewriteURLs(child, base)
+	}
+}
+
+// resolveAttr resolves a URL attribute against base. It reports whether the
+// attribute holds a valid URL.
+func resolveAttr(node *html.Node, name string, base *url.URL) bool {
+	value := strings.TrimSpace(dom.GetAttribute(node, name))
+	if value == "" {
+		return false
+	}
+
+	ref, err := url.Parse(value)
+	if err != nil {
+		dom.RemoveAttribute(node, name)
+		return false
+	}
+
+	if base != nil {
+		ref = base.ResolveReference(ref)
+	}
+	dom.SetAttribute(node, name, ref.String())
+	return true
+}
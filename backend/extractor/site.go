@@ -0,0 +1,166 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"read-it-later/backend/model"
+)
+
+// SiteExtractor extracts articles from the pages it matches. Extractors are
+// tried in priority order, so site-specific extractors can take over from the
+// generic readability extractor without changing the dispatch in Extract.
+type SiteExtractor interface {
+	// Match reports whether the extractor can handle the page at u.
+	Match(u *url.URL) bool
+	// Extract fetches the page at u and returns its article. It returns an
+	// error wrapping ErrNoContent to let the next matching extractor try.
+	Extract(ctx context.Context, u *url.URL) (model.Article, error)
+}
+
+// ErrNoContent is returned by a SiteExtractor that could not find the article
+// content of a page. Extract then moves on to the next matching extractor.
+var ErrNoContent = errors.New("no article content found")
+
+// Priorities of the built-in extractors. Extractors with a higher priority
+// are tried first.
+const (
+	PrioritySite        = 100
+	PriorityReadability = 0
+	PriorityFallback    = -100
+)
+
+type registration struct {
+	priority  int
+	extractor SiteExtractor
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// Register adds a site extractor with the given priority. Extractors with the
+// same priority are tried in the order they were registered.
+func Register(extractor SiteExtractor, priority int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, registration{priority: priority, extractor: extractor})
+	sort.SliceStable(registry, func(i, j int) bool {
+		return registry[i].priority > registry[j].priority
+	})
+}
+
+// matching returns the registered extractors that match u, in priority order.
+func matching(u *url.URL) []SiteExtractor {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var extractors []SiteExtractor
+	for _, r := range registry {
+		if r.extractor.Match(u) {
+			extractors = append(extractors, r.extractor)
+		}
+	}
+	return extractors
+}
+
+// Extract fetches the content from a URL using the first registered site
+// extractor that matches it and finds its content.
+func Extract(urlString string) (model.Article, error) {
+	return ExtractContext(context.Background(), urlString)
+}
+
+// ExtractContext is like Extract but runs the extractors with ctx, which may
+// carry a recorder set by WithRecorder.
+func ExtractContext(ctx context.Context, urlString string) (model.Article, error) {
+	return extract(ctx, urlString, true)
+}
+
+// RefreshContext is like ExtractContext but, for refreshing a saved article,
+// returns an error wrapping ErrNoContent instead of a placeholder when no
+// extractor finds the content, so the stored content is kept.
+func RefreshContext(ctx context.Context, urlString string) (model.Article, error) {
+	return extract(ctx, urlString, false)
+}
+
+// extract runs the extractors matching urlString in priority order. The
+// fallback placeholder is only produced if placeholder is true.
+func extract(ctx context.Context, urlString string, placeholder bool) (model.Article, error) {
+	// Parse the URL string
+	parsedURL, err := url.ParseRequestURI(urlString)
+	if err != nil {
+		return model.Article{}, err
+	}
+
+	var lastErr error
+	for _, extractor := range matching(parsedURL) {
+		if _, ok := extractor.(fallbackExtractor); ok && !placeholder {
+			continue
+		}
+		article, err := extractor.Extract(ctx, parsedURL)
+		if errors.Is(err, ErrNoContent) {
+			// Let the next extractor try
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return model.Article{}, err
+		}
+
+		return finishArticle(article, urlString), nil
+	}
+
+	if lastErr != nil {
+		return model.Article{}, lastErr
+	}
+	return model.Article{}, fmt.Errorf("%w: no extractor handled %s", ErrNoContent, urlString)
+}
+
+// ExtractWithBrowser renders the page in a headless browser before extracting
+// its content, for pages that only load their content with JavaScript. The
+// first matching BrowserSiteExtractor is used, or a generic one if none
+// matches.
+func ExtractWithBrowser(urlString string) (model.Article, error) {
+	return ExtractWithBrowserContext(context.Background(), urlString)
+}
+
+// ExtractWithBrowserContext is like ExtractWithBrowser but renders the page
+// with ctx, which may carry a recorder set by WithRecorder.
+func ExtractWithBrowserContext(ctx context.Context, urlString string) (model.Article, error) {
+	parsedURL, err := url.ParseRequestURI(urlString)
+	if err != nil {
+		return model.Article{}, err
+	}
+
+	browserExtractor := genericBrowserExtractor
+	for _, extractor := range matching(parsedURL) {
+		if site, ok := extractor.(*BrowserSiteExtractor); ok {
+			browserExtractor = site
+			break
+		}
+	}
+
+	article, err := browserExtractor.Extract(ctx, parsedURL)
+	if err != nil {
+		return model.Article{}, err
+	}
+
+	return finishArticle(article, urlString), nil
+}
+
+// finishArticle fills in the fields derived from every extracted article.
+func finishArticle(article model.Article, urlString string) model.Article {
+	article.URL = urlString
+	if article.ContentHTML != "" {
+		article.Markdown = HTMLToMarkdown(article.ContentHTML)
+	} else {
+		article.Markdown = article.Content
+	}
+	return article
+}
@@ -0,0 +1,289 @@
+package extractor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"read-it-later/backend/model"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/go-shiori/dom"
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+// PriorityRules is the priority of the extractor applying site config files.
+// Rules are tried after the built-in site extractors and before readability.
+const PriorityRules = 50
+
+// SiteConfig holds the extraction rules for a site, in the FiveFilters /
+// Wallabag ftr-site-config format. See
+// https://help.fivefilters.org/full-text-rss/site-patterns.html for details.
+type SiteConfig struct {
+	Title          []string          // XPath expressions for the title, tried in order
+	Body           []string          // XPath expressions for the content, tried in order
+	Strip          []string          // XPath expressions for elements to remove
+	StripIDOrClass []string          // 移除 id 或 class 包含这些字符串的元素
+	SinglePageLink []string          // XPath expressions for a link to the single-page view
+	HTTPHeaders    map[string]string // Request headers to send when fetching the site
+}
+
+func init() {
+	Register(siteConfigExtractor{}, PriorityRules)
+}
+
+var (
+	siteConfigMu  sync.RWMutex
+	siteConfigDir string
+)
+
+// SetSiteConfigDir sets the directory site config files are read from. Each
+// file is named after the host it applies to, such as example.com.txt, or
+// .example.com.txt to include subdomains. Files are read when a page is
+// extracted, so rules can be added without restarting the server.
+func SetSiteConfigDir(dir string) {
+	siteConfigMu.Lock()
+	defer siteConfigMu.Unlock()
+	siteConfigDir = dir
+}
+
+// ParseSiteConfig reads site config directives from r. Directives that are
+// not supported are ignored.
+func ParseSiteConfig(r io.Reader) (*SiteConfig, error) {
+	config := &SiteConfig{HTTPHeaders: map[string]string{}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		// Directives with an argument look like http_header(user-agent): value
+		var arg string
+		if open := strings.Index(key, "("); open != -1 && strings.HasSuffix(key, ")") {
+			key, arg = key[:open], key[open+1:len(key)-1]
+		}
+
+		switch key {
+		case "title":
+			config.Title = append(config.Title, value)
+		case "body":
+			config.Body = append(config.Body, value)
+		case "strip":
+			config.Strip = append(config.Strip, value)
+		case "strip_id_or_class":
+			config.StripIDOrClass = append(config.StripIDOrClass, strings.Trim(value, `"'`))
+		case "single_page_link":
+			config.SinglePageLink = append(config.SinglePageLink, value)
+		case "http_header":
+			if arg != "" {
+				config.HTTPHeaders[arg] = value
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// siteConfigFor returns the config for a host, or nil if there is none. An
+// exact match such as example.com.txt is preferred over wildcard files such
+// as .example.com.txt, and closer parent domains over further ones.
+func siteConfigFor(host string) *SiteConfig {
+	siteConfigMu.RLock()
+	dir := siteConfigDir
+	siteConfigMu.RUnlock()
+
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if dir == "" || host == "" || strings.Contains(host, "..") || strings.ContainsAny(host, `/\`) {
+		return nil
+	}
+
+	names := []string{host}
+	for domain := host; strings.Contains(domain, "."); domain = domain[strings.Index(domain, ".")+1:] {
+		names = append(names, "."+domain)
+	}
+
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(dir, name+".txt"))
+		if err != nil {
+			continue
+		}
+
+		config, err := ParseSiteConfig(file)
+		file.Close()
+		if err != nil {
+			log.Printf("Error reading site config %s: %v", name, err)
+			continue
+		}
+		return config
+	}
+
+	return nil
+}
+
+// siteConfigExtractor applies site config rules to pages of the sites it has
+// a config file for. Pages whose content the rules do not find are parsed by
+// go-readability after the strip rules have been applied.
+type siteConfigExtractor struct{}
+
+// Match implements SiteExtractor.
+func (siteConfigExtractor) Match(u *url.URL) bool {
+	return siteConfigFor(u.Hostname()) != nil
+}
+
+// Extract implements SiteExtractor.
+func (siteConfigExtractor) Extract(ctx context.Context, u *url.URL) (model.Article, error) {
+	config := siteConfigFor(u.Hostname())
+	if config == nil {
+		return model.Article{}, fmt.Errorf("%w: no site config for %s", ErrNoContent, u.Hostname())
+	}
+
+	doc, err := fetchDocument(ctx, u.String(), config.HTTPHeaders)
+	if err != nil {
+		return model.Article{}, err
+	}
+
+	// Switch to the single-page view of multi-page articles
+	if link := firstMatch(doc, config.SinglePageLink); link != nil {
+		// The expression may select the link element or its href attribute
+		href := strings.TrimSpace(htmlquery.InnerText(link))
+		if dom.HasAttribute(link, "href") {
+			href = strings.TrimSpace(dom.GetAttribute(link, "href"))
+		}
+		if singlePageURL, err := u.Parse(href); err == nil && href != "" {
+			if singlePage, err := fetchDocument(ctx, singlePageURL.String(), config.HTTPHeaders); err == nil {
+				doc = singlePage
+			} else {
+				log.Printf("Error fetching single-page view %s: %v", singlePageURL, err)
+			}
+		}
+	}
+
+	for _, expr := range config.Strip {
+		removeNodes(doc, expr)
+	}
+	for _, idOrClass := range config.StripIDOrClass {
+		if strings.Contains(idOrClass, "'") {
+			continue
+		}
+		removeNodes(doc, fmt.Sprintf("//*[contains(@class, '%s') or contains(@id, '%s')]", idOrClass, idOrClass))
+	}
+
+	var title string
+	if node := firstMatch(doc, config.Title); node != nil {
+		title = strings.TrimSpace(htmlquery.InnerText(node))
+	}
+
+	var content, contentHTML string
+	for _, expr := range config.Body {
+		nodes, err := htmlquery.QueryAll(doc, expr)
+		if err != nil {
+			log.Printf("Invalid body XPath %q: %v", expr, err)
+			continue
+		}
+
+		var parts, htmlParts []string
+		for _, node := range nodes {
+			if text := strings.TrimSpace(dom.TextContent(node)); text != "" {
+				parts = append(parts, text)
+				htmlParts = append(htmlParts, dom.OuterHTML(node))
+			}
+		}
+		if len(parts) > 0 {
+			content = strings.Join(parts, "\n\n")
+			contentHTML = strings.Join(htmlParts, "\n")
+			break
+		}
+	}
+
+	// Readability still provides the metadata, and the content when the
+	// body rules do not match
+	article, err := readability.FromDocument(doc, u)
+	if err != nil {
+		return model.Article{}, err
+	}
+	if title != "" {
+		article.Title = title
+	}
+	if content != "" {
+		article.TextContent = content
+		article.Content = contentHTML
+	} else if isContentEmpty(article) {
+		return model.Article{}, fmt.Errorf("%w: site config rules found too little content", ErrNoContent)
+	}
+
+	result := model.Article{
+		URL:         u.String(),
+		Title:       article.Title,
+		Content:     article.TextContent,
+		ContentHTML: SanitizeHTML(article.Content, u),
+		Excerpt:     article.Excerpt,
+		ImageURL:    ProcessImageURL(article.Image),
+	}
+
+	return result, nil
+}
+
+// fetchDocument fetches and parses an HTML page.
+func fetchDocument(ctx context.Context, urlString string, headers map[string]string) (*html.Node, error) {
+	resp, err := fetchPage(ctx, urlString, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return dom.Parse(resp.Body)
+}
+
+// firstMatch returns the first node matched by any of the XPath expressions,
+// tried in order.
+func firstMatch(doc *html.Node, exprs []string) *html.Node {
+	for _, expr := range exprs {
+		node, err := htmlquery.Query(doc, expr)
+		if err != nil {
+			log.Printf("Invalid XPath %q: %v", expr, err)
+			continue
+		}
+		if node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// removeNodes removes every element matched by an XPath expression.
+func removeNodes(doc *html.Node, expr string) {
+	nodes, err := htmlquery.QueryAll(doc, expr)
+	if err != nil {
+		log.Printf("Invalid strip XPath %q: %v", expr, err)
+		return
+	}
+	for _, node := range nodes {
+		if node.Parent != nil {
+			node.Parent.RemoveChild(node)
+		}
+	}
+}
@@ -11,6 +11,11 @@ func ProcessImageURL(imageURL string) string {
 		return ""
 	}
 
+	// Already proxied, such as when sanitizing stored content again
+	if strings.HasPrefix(imageURL, "/api/proxy/image?") {
+		return imageURL
+	}
+
 	// Handle WeChat images (mmbiz.qpic.cn) that have anti-hotlinking protection
 	if strings.Contains(imageURL, "mmbiz.qpic.cn") {
 		// Convert to proxy URL to bypass anti-hotlinking
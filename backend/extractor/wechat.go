@@ -0,0 +1,49 @@
+package extractor
+
+func init() {
+	Register(&BrowserSiteExtractor{
+		Domains:       []string{"mp.weixin.qq.com"},
+		ContentScript: wechatContentScript,
+	}, PrioritySite)
+}
+
+// wechatContentScript extracts WeChat article content
+const wechatContentScript = `
+function extractWechatContent() {
+	// Try multiple selectors for WeChat content
+	const selectors = [
+		'.rich_media_content',
+		'#js_content',
+		'.rich_media_area_primary .rich_media_content',
+		'[data-role="main"]'
+	];
+	
+	for (const selector of selectors) {
+		const element = document.querySelector(selector);
+		if (element) {
+			// Clean up the content
+			const clone = element.cloneNode(true);
+			
+			// Remove unwanted elements
+			const unwanted = clone.querySelectorAll('script, style, .rich_media_tool, .rich_media_meta, [data-role="bottom"]');
+			unwanted.forEach(el => el.remove());
+			
+			const text = clone.innerText || clone.textContent || '';
+			if (text.trim().length > 100) {
+				return { text: text.trim(), html: clone.innerHTML };
+			}
+		}
+	}
+	
+	// Fallback: try to get any meaningful text
+	const body = document.querySelector('body');
+	if (body) {
+		const text = body.innerText || body.textContent || '';
+		return { text: text.trim(), html: body.innerHTML };
+	}
+	
+	return { text: '', html: '' };
+}
+
+extractWechatContent();
+`
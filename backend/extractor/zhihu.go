@@ -0,0 +1,52 @@
+package extractor
+
+func init() {
+	Register(&BrowserSiteExtractor{
+		Domains:       []string{"zhihu.com"},
+		ContentScript: zhihuContentScript,
+	}, PrioritySite)
+}
+
+// zhihuContentScript extracts Zhihu article content
+const zhihuContentScript = `
+function extractZhihuContent() {
+	// Try multiple selectors for Zhihu content
+	const selectors = [
+		'.Post-RichTextContainer',
+		'.RichText',
+		'.Post-content',
+		'.ArticleItem-content',
+		'[data-testid="article-content"]'
+	];
+	
+	for (const selector of selectors) {
+		const element = document.querySelector(selector);
+		if (element) {
+			// Clean up the content
+			const clone = element.cloneNode(true);
+			
+			// Remove unwanted elements
+			const unwanted = clone.querySelectorAll('script, style, .Post-NormalMain, .ContentItem-actions');
+			unwanted.forEach(el => el.remove());
+			
+			const text = clone.innerText || clone.textContent || '';
+			if (text.trim().length > 100) {
+				return { text: text.trim(), html: clone.innerHTML };
+			}
+		}
+	}
+	
+	// Fallback: try article tag
+	const article = document.querySelector('article');
+	if (article) {
+		const text = article.innerText || article.textContent || '';
+		if (text.trim().length > 100) {
+			return { text: text.trim(), html: article.innerHTML };
+		}
+	}
+	
+	return { text: '', html: '' };
+}
+
+extractZhihuContent();
+`
@@ -0,0 +1,358 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNotFeed is returned for documents that are not RSS, Atom or JSON feeds.
+var ErrNotFeed = errors.New("not an RSS, Atom or JSON feed")
+
+// Feed is the content of a feed document.
+type Feed struct {
+	Title   string
+	SiteURL string
+	// Items are in the order of the document, usually newest first.
+	Items []Item
+}
+
+// Item is an entry of a feed.
+type Item struct {
+	// GUID identifies the entry; feeds without IDs use the link instead.
+	GUID      string
+	URL       string
+	Title     string
+	Published time.Time
+}
+
+// Parse reads an RSS 2.0, RSS 1.0, Atom or JSON Feed document. Relative links
+// are resolved against base, the URL of the feed.
+func Parse(data []byte, base *url.URL) (Feed, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var feed Feed
+	var err error
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		feed, err = parseJSONFeed(trimmed)
+	} else {
+		feed, err = parseXMLFeed(data)
+	}
+	if err != nil {
+		return Feed{}, err
+	}
+
+	feed.Title = strings.TrimSpace(feed.Title)
+	feed.SiteURL = resolve(base, feed.SiteURL)
+	items := feed.Items[:0]
+	for _, item := range feed.Items {
+		item.URL = resolve(base, item.URL)
+		item.Title = strings.TrimSpace(item.Title)
+		item.GUID = strings.TrimSpace(item.GUID)
+		if item.GUID == "" {
+			item.GUID = item.URL
+		}
+		if item.GUID != "" {
+			items = append(items, item)
+		}
+	}
+	feed.Items = items
+	return feed, nil
+}
+
+// resolve resolves a possibly relative link against base.
+func resolve(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || base == nil {
+		return link
+	}
+	resolved, err := base.Parse(link)
+	if err != nil {
+		return link
+	}
+	return resolved.String()
+}
+
+// xmlLink is a link element: RSS links hold the URL as text, Atom links in the
+// href attribute.
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// rssChannel is the channel of an RSS 2.0 document, or of an RSS 1.0 one,
+// whose items are next to it rather than inside.
+type rssChannel struct {
+	Title string    `xml:"title"`
+	Links []xmlLink `xml:"link"`
+	Items []rssItem `xml:"item"`
+}
+
+// rssItem is an item of an RSS document.
+type rssItem struct {
+	About   string    `xml:"about,attr"`
+	GUID    string    `xml:"guid"`
+	Title   string    `xml:"title"`
+	Links   []xmlLink `xml:"link"`
+	PubDate string    `xml:"pubDate"`
+	Date    string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+// rssDocument is an RSS 2.0 (<rss>) or RSS 1.0 (<rdf:RDF>) document.
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"`
+}
+
+// atomDocument is an Atom feed document.
+type atomDocument struct {
+	Title   string      `xml:"title"`
+	Links   []xmlLink   `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is an entry of an Atom feed.
+type atomEntry struct {
+	ID        string    `xml:"id"`
+	Title     string    `xml:"title"`
+	Links     []xmlLink `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+}
+
+// parseXMLFeed reads an RSS or Atom document, telling them apart by the root
+// element.
+func parseXMLFeed(data []byte) (Feed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return Feed{}, ErrNotFeed
+		}
+		if err != nil {
+			return Feed{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	switch strings.ToLower(root.Name.Local) {
+	case "rss", "rdf":
+		var doc rssDocument
+		if err := decoder.DecodeElement(&doc, &root); err != nil {
+			return Feed{}, err
+		}
+		feed := Feed{Title: doc.Channel.Title, SiteURL: textLink(doc.Channel.Links)}
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			link := textLink(item.Links)
+			if link == "" && looksLikeURL(item.GUID) {
+				link = item.GUID
+			}
+			if link == "" {
+				link = item.About
+			}
+			guid := item.GUID
+			if guid == "" {
+				guid = item.About
+			}
+			published := parseDate(item.PubDate)
+			if published.IsZero() {
+				published = parseDate(item.Date)
+			}
+			feed.Items = append(feed.Items, Item{GUID: guid, URL: link, Title: item.Title, Published: published})
+		}
+		return feed, nil
+
+	case "feed":
+		var doc atomDocument
+		if err := decoder.DecodeElement(&doc, &root); err != nil {
+			return Feed{}, err
+		}
+		feed := Feed{Title: doc.Title, SiteURL: alternateLink(doc.Links)}
+		for _, entry := range doc.Entries {
+			published := parseDate(entry.Published)
+			if published.IsZero() {
+				published = parseDate(entry.Updated)
+			}
+			feed.Items = append(feed.Items, Item{
+				GUID:      entry.ID,
+				URL:       alternateLink(entry.Links),
+				Title:     entry.Title,
+				Published: published,
+			})
+		}
+		return feed, nil
+	}
+
+	return Feed{}, ErrNotFeed
+}
+
+// textLink returns the first RSS link, skipping the atom:link elements many
+// RSS feeds include.
+func textLink(links []xmlLink) string {
+	for _, link := range links {
+		if link.Href == "" && strings.TrimSpace(link.Text) != "" {
+			return strings.TrimSpace(link.Text)
+		}
+	}
+	return ""
+}
+
+// alternateLink returns the alternate link of an Atom feed or entry, which
+// points to its web page.
+func alternateLink(links []xmlLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// looksLikeURL reports whether an RSS GUID is a web address, as permalink
+// GUIDs are.
+func looksLikeURL(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// jsonFeed is a JSON Feed document (https://jsonfeed.org).
+type jsonFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		ExternalURL   string          `json:"external_url"`
+		Title         string          `json:"title"`
+		DatePublished string          `json:"date_published"`
+	} `json:"items"`
+}
+
+// parseJSONFeed reads a JSON Feed document.
+func parseJSONFeed(data []byte) (Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Feed{}, err
+	}
+	if !strings.Contains(doc.Version, "jsonfeed.org") {
+		return Feed{}, ErrNotFeed
+	}
+
+	feed := Feed{Title: doc.Title, SiteURL: doc.HomePageURL}
+	for _, item := range doc.Items {
+		// IDs should be strings, but some feeds use numbers
+		var id string
+		if err := json.Unmarshal(item.ID, &id); err != nil {
+			id = strings.TrimSpace(string(item.ID))
+		}
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		feed.Items = append(feed.Items, Item{
+			GUID:      id,
+			URL:       link,
+			Title:     item.Title,
+			Published: parseDate(item.DatePublished),
+		})
+	}
+	return feed, nil
+}
+
+// dateLayouts are the layouts of dates in feeds. RSS uses RFC 822 dates, often
+// written loosely; Atom and JSON Feed use RFC 3339.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseDate reads a feed date, returning the zero time if it cannot be read.
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// feedTypes are the media types of feeds advertised by web pages.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+}
+
+// Discover returns the first feed a web page advertises with
+// <link rel="alternate">, resolved against base, or an empty string.
+func Discover(page []byte, base *url.URL) string {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return ""
+	}
+
+	var found string
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if found != "" {
+			return
+		}
+		if node.Type == html.ElementNode && node.Data == "link" {
+			var rel, typ, href string
+			for _, attr := range node.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = strings.ToLower(attr.Val)
+				case "type":
+					typ = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "href":
+					href = attr.Val
+				}
+			}
+			if strings.Contains(rel, "alternate") && feedTypes[typ] && strings.TrimSpace(href) != "" {
+				found = resolve(base, href)
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return found
+}
@@ -0,0 +1,332 @@
+// Package feeds subscribes users to RSS, Atom and JSON feeds and saves their
+// new entries as articles. A poller fetches due feeds with conditional
+// requests, records every entry by GUID so each is saved once, and queues
+// the saved articles for extraction like articles saved by hand.
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"read-it-later/backend/model"
+	"read-it-later/backend/queue"
+	"read-it-later/backend/store"
+)
+
+const (
+	// checkInterval is how often the poller looks for due feeds.
+	checkInterval = time.Minute
+	// pollers is the number of feeds fetched at the same time.
+	pollers = 4
+	// fetchTimeout limits the time to download a feed.
+	fetchTimeout = 30 * time.Second
+	// maxFeedSize limits the size of a feed document.
+	maxFeedSize = 10 << 20
+	// maxRetryDelay caps the delay before polling a failing feed again.
+	maxRetryDelay = 24 * time.Hour
+	// MaxBackfill limits the entries already in a feed that are saved when
+	// subscribing.
+	MaxBackfill = 50
+)
+
+// ErrNoFeed is returned when subscribing to a URL that is neither a feed nor
+// a web page advertising one.
+var ErrNoFeed = errors.New("no feed found at URL")
+
+var (
+	intervalMu   sync.RWMutex
+	pollInterval = 30 * time.Minute
+)
+
+// wake asks the poller to look for due feeds right away.
+var wake = make(chan struct{}, 1)
+
+// SetPollInterval sets how often feeds are polled, such as "30m". Feeds are
+// polled at most once a minute.
+func SetPollInterval(value string) error {
+	interval, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	if interval < checkInterval {
+		return fmt.Errorf("interval %s shorter than %s", interval, checkInterval)
+	}
+
+	intervalMu.Lock()
+	defer intervalMu.Unlock()
+	pollInterval = interval
+	return nil
+}
+
+// nextPoll returns when to poll a feed next after the given number of
+// consecutive failures, backing off exponentially while it fails.
+func nextPoll(failures int) time.Time {
+	intervalMu.RLock()
+	delay := pollInterval
+	intervalMu.RUnlock()
+
+	for i := 0; i < failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return time.Now().Add(min(delay, maxRetryDelay))
+}
+
+// Start starts polling the feeds of all users in the background.
+func Start() {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			pollDue()
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// Wake makes the poller look for due feeds now, such as after a user asked
+// for a feed to be refreshed.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// pollDue polls the feeds that are due, a few at a time.
+func pollDue() {
+	for {
+		due, err := store.GetDueFeeds(100)
+		if err != nil {
+			log.Printf("Error loading due feeds: %v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		sem := make(chan struct{}, pollers)
+		var wg sync.WaitGroup
+		for _, feed := range due {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(feed model.Feed) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				poll(feed)
+			}(feed)
+		}
+		wg.Wait()
+	}
+}
+
+// poll fetches a feed and saves its new entries. Failures are recorded on the
+// feed, which is polled again later.
+func poll(feed model.Feed) {
+	resp, err := fetch(feed.URL, feed.ETag, feed.LastModified)
+	if err != nil {
+		failed(feed, err)
+		return
+	}
+
+	if !resp.notModified {
+		parsed, err := Parse(resp.body, resp.url)
+		if err != nil {
+			failed(feed, err)
+			return
+		}
+
+		if saved := saveEntries(feed, parsed.Items, -1); saved > 0 {
+			log.Printf("Saved %d new entries of feed %d", saved, feed.ID)
+			queue.Notify()
+		}
+		feed.ETag, feed.LastModified = resp.etag, resp.lastModified
+		feed.Title, feed.SiteURL = parsed.Title, parsed.SiteURL
+	}
+
+	if err := store.FeedPolled(feed, nextPoll(0)); err != nil {
+		log.Printf("Error recording poll of feed %d: %v", feed.ID, err)
+	}
+}
+
+// failed records a failed poll of a feed.
+func failed(feed model.Feed, pollErr error) {
+	log.Printf("Error polling feed %d (%s): %v", feed.ID, feed.URL, pollErr)
+	if err := store.FeedPollFailed(feed.ID, pollErr.Error(), nextPoll(feed.Failures+1)); err != nil {
+		log.Printf("Error recording poll of feed %d: %v", feed.ID, err)
+	}
+}
+
+// saveEntries records the entries of a feed and saves the new ones as
+// articles with the feed's tags, oldest first. Only the first save entries
+// of the document, usually the newest, are saved; the others are recorded
+// as seen. A negative save saves all of them. It returns the number of
+// articles saved.
+func saveEntries(feed model.Feed, items []Item, save int) int {
+	saved := 0
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		entry := model.FeedEntry{FeedID: feed.ID, GUID: item.GUID, URL: item.URL, Title: item.Title}
+		if !item.Published.IsZero() {
+			entry.PublishedAt = &item.Published
+		}
+
+		entryID, isNew, err := store.AddFeedEntry(entry)
+		if err != nil {
+			log.Printf("Error recording entry %s of feed %d: %v", item.GUID, feed.ID, err)
+			continue
+		}
+		if !isNew || (save >= 0 && i >= save) || !isArticleURL(item.URL) {
+			continue
+		}
+
+		title := item.Title
+		if title == "" {
+			title = item.URL
+		}
+		articleID, err := store.ImportArticle(model.Article{UserID: feed.UserID, URL: item.URL, Title: title}, feed.Tags, time.Time{})
+		if err != nil && !errors.Is(err, store.ErrArticleExists) {
+			log.Printf("Error saving entry %s of feed %d: %v", item.URL, feed.ID, err)
+			// Forget the entry so it is saved on the next poll
+			if err := store.DeleteFeedEntry(entryID); err != nil {
+				log.Printf("Error forgetting entry %d of feed %d: %v", entryID, feed.ID, err)
+			}
+			continue
+		}
+		if err == nil {
+			saved++
+		}
+		if err := store.SetFeedEntryArticle(entryID, articleID); err != nil {
+			log.Printf("Error recording article of entry %d: %v", entryID, err)
+		}
+	}
+	return saved
+}
+
+// isArticleURL reports whether an entry links to a web page that can be saved.
+func isArticleURL(link string) bool {
+	parsed, err := url.ParseRequestURI(link)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// Subscribe subscribes a user to the feed at feedURL, or to the feed a web
+// page at feedURL advertises. Entries already in the feed are recorded so
+// only later ones are saved, except the newest backfill entries, which are
+// saved right away.
+func Subscribe(userID int, feedURL string, tags []string, backfill int) (model.Feed, error) {
+	resp, err := fetch(feedURL, "", "")
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	parsed, err := Parse(resp.body, resp.url)
+	if err != nil {
+		// Look for a feed advertised by the page
+		discovered := Discover(resp.body, resp.url)
+		if discovered == "" {
+			return model.Feed{}, ErrNoFeed
+		}
+		if resp, err = fetch(discovered, "", ""); err != nil {
+			return model.Feed{}, err
+		}
+		if parsed, err = Parse(resp.body, resp.url); err != nil {
+			return model.Feed{}, ErrNoFeed
+		}
+	}
+
+	// The feed is not due until its entries are recorded, or the poller could
+	// save all of them instead of the newest backfill ones
+	feed, err := store.CreateFeed(model.Feed{
+		UserID:     userID,
+		URL:        resp.url.String(),
+		Title:      parsed.Title,
+		SiteURL:    parsed.SiteURL,
+		Tags:       tags,
+		NextPollAt: nextPoll(0),
+	})
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	if saveEntries(feed, parsed.Items, backfill) > 0 {
+		queue.Notify()
+	}
+	feed.ETag, feed.LastModified = resp.etag, resp.lastModified
+	if err := store.FeedPolled(feed, nextPoll(0)); err != nil {
+		return model.Feed{}, err
+	}
+	return store.GetFeedByID(feed.ID, userID)
+}
+
+// fetchResult is the response to a feed request.
+type fetchResult struct {
+	// url is the URL of the feed after redirects.
+	url          *url.URL
+	notModified  bool
+	body         []byte
+	etag         string
+	lastModified string
+}
+
+// fetch downloads a feed, sending the validators of the previous response so
+// the server can answer that it has not changed.
+func fetch(feedURL string, etag string, lastModified string) (fetchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ReadItLater feed fetcher)")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, application/json;q=0.8, text/html;q=0.7, */*;q=0.5")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	result := fetchResult{
+		url:          resp.Request.URL,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		result.notModified = true
+		return result, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fetchResult{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "image/") ||
+		strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/") {
+		return fetchResult{}, fmt.Errorf("unexpected content type %s", mediaType)
+	}
+
+	result.body, err = io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return fetchResult{}, err
+	}
+	if len(result.body) > maxFeedSize {
+		return fetchResult{}, fmt.Errorf("feed larger than %d bytes", maxFeedSize)
+	}
+	return result, nil
+}
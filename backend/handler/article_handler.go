@@ -3,15 +3,20 @@ package handler
 import (
 	"database/sql"
 	"net/http"
-	"read-it-later/backend/extractor"
+	"net/url"
+	"read-it-later/backend/events"
 	"read-it-later/backend/model"
+	"read-it-later/backend/queue"
 	"read-it-later/backend/store"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AddArticle handles the creation of a new article from a URL.
+// AddArticle handles saving a new article from a URL. The article is stored
+// in the pending state and its content is extracted by a background job.
 func AddArticle(c *gin.Context) {
 	// 获取用户ID
 	userID, exists := c.Get("user_id")
@@ -29,27 +34,126 @@ func AddArticle(c *gin.Context) {
 		return
 	}
 
-	// Extract content from the URL
-	article, err := extractor.Extract(json.URL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extract article: " + err.Error()})
+	parsedURL, err := url.ParseRequestURI(json.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
 		return
 	}
 
-	// 设置用户ID
-	article.UserID = userID.(int)
+	// Save a placeholder until the content has been extracted
+	article := model.Article{
+		UserID: userID.(int),
+		URL:    json.URL,
+		Title:  json.URL,
+	}
 
-	// Save the article to the database
-	savedArticle, err := store.SaveArticle(article)
+	savedArticle, job, err := store.SaveArticleForExtraction(article)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save article: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, savedArticle)
+	queue.Notify()
+
+	c.JSON(http.StatusAccepted, struct {
+		model.Article
+		Job model.Job `json:"job"`
+	}{savedArticle, job})
+}
+
+// parseListOptions reads the pagination, sorting and filter query parameters
+// shared by the article list and search endpoints. It returns an error message
+// suitable for the client if a parameter is invalid.
+func parseListOptions(c *gin.Context, search bool) (store.ListOptions, string) {
+	opts := store.ListOptions{
+		Sort:   c.Query("sort"),
+		Order:  c.DefaultQuery("order", "desc"),
+		Cursor: c.Query("cursor"),
+		Filter: store.ArticleFilter{
+			State:  c.Query("state"),
+			Domain: c.Query("domain"),
+		},
+	}
+
+	if opts.Sort == "" {
+		opts.Sort = "created_at"
+		if search {
+			opts.Sort = "relevance"
+		}
+	}
+	if !store.IsValidSort(opts.Sort, search) {
+		return opts, "Invalid sort option"
+	}
+
+	if opts.Order != "asc" && opts.Order != "desc" {
+		return opts, "Invalid sort order"
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > store.MaxPageSize {
+			return opts, "Limit must be between 1 and " + strconv.Itoa(store.MaxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	if !store.IsValidArticleState(opts.Filter.State) {
+		return opts, "Invalid state filter"
+	}
+
+	if favoriteParam := c.Query("favorite"); favoriteParam != "" {
+		favorite, err := strconv.ParseBool(favoriteParam)
+		if err != nil {
+			return opts, "Invalid favorite filter"
+		}
+		opts.Filter.Favorite = &favorite
+	}
+
+	if tagsParam := c.Query("tags"); tagsParam != "" {
+		for _, tag := range strings.Split(tagsParam, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				opts.Filter.Tags = append(opts.Filter.Tags, tag)
+			}
+		}
+	}
+
+	if fromParam := c.Query("from"); fromParam != "" {
+		from, err := parseDateParam(fromParam, false)
+		if err != nil {
+			return opts, "Invalid from date"
+		}
+		opts.Filter.CreatedAfter = &from
+	}
+
+	if toParam := c.Query("to"); toParam != "" {
+		to, err := parseDateParam(toParam, true)
+		if err != nil {
+			return opts, "Invalid to date"
+		}
+		opts.Filter.CreatedBefore = &to
+	}
+
+	return opts, ""
 }
 
-// GetArticles handles listing all articles for the authenticated user.
+// parseDateParam parses an RFC 3339 timestamp or a YYYY-MM-DD date. A date
+// used as the end of a range covers the whole day.
+func parseDateParam(value string, endOfRange bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GetArticles handles listing the authenticated user's articles one page at a time.
 func GetArticles(c *gin.Context) {
 	// 获取用户ID
 	userID, exists := c.Get("user_id")
@@ -58,15 +162,26 @@ func GetArticles(c *gin.Context) {
 		return
 	}
 
-	articles, err := store.GetAllArticles(userID.(int))
+	opts, errMsg := parseListOptions(c, false)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	page, err := store.ListArticles(userID.(int), opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+		if err == store.ErrInvalidCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+		}
 		return
 	}
-	c.JSON(http.StatusOK, articles)
+	c.JSON(http.StatusOK, page)
 }
 
-// SearchArticles handles searching articles by title or tags.
+// SearchArticles handles full-text searching articles or searching them by tag,
+// one page at a time.
 func SearchArticles(c *gin.Context) {
 	// 获取用户ID
 	userID, exists := c.Get("user_id")
@@ -83,26 +198,41 @@ func SearchArticles(c *gin.Context) {
 		return
 	}
 
-	var articles []model.Article
+	// Relevance only applies to full-text search
+	opts, errMsg := parseListOptions(c, tag == "")
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	var page model.ArticlePage
 	var err error
 
 	if tag != "" {
 		// Search by tag
-		articles, err = store.SearchArticlesByTag(tag, userID.(int))
-	} else if query != "" {
-		// Search by title
-		articles, err = store.SearchArticlesByTitle(query, userID.(int))
+		opts.Filter.TagQuery = tag
+		page, err = store.ListArticles(userID.(int), opts)
+	} else {
+		// Full-text search over title, excerpt, content and tags
+		page, err = store.SearchArticles(query, userID.(int), opts)
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search articles"})
+		if err == store.ErrInvalidCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search articles"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, articles)
+	c.JSON(http.StatusOK, page)
 }
 
 // GetArticle handles retrieving a single article by its ID for the authenticated user.
+// With ?format=markdown the article is returned as a Markdown document, and
+// with &download=true as a .md file attachment. ?format=epub returns it as an
+// EPUB book.
 func GetArticle(c *gin.Context) {
 	// 获取用户ID
 	userID, exists := c.Get("user_id")
@@ -128,9 +258,109 @@ func GetArticle(c *gin.Context) {
 		return
 	}
 
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, article)
+	case "markdown":
+		writeMarkdown(c, article, c.Query("download") == "true")
+	case "epub":
+		book, err := articleEPUB(article)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build EPUB"})
+			return
+		}
+		writeEPUB(c, book, articleFilename(article, ".epub"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+	}
+}
+
+// UpdateArticle handles toggling the read, archived and favorite states of an article.
+func UpdateArticle(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	var update model.ArticleStateUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	article, err := store.UpdateArticleState(id, userID.(int), update)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article"})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, article)
 }
 
+// RefreshArticle handles queueing a new extraction of an existing article,
+// optionally forcing the headless browser. The stored content is replaced
+// once extraction succeeds; tags, highlights and reading state are kept.
+func RefreshArticle(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	// 请求体可选
+	var json struct {
+		ForceBrowser bool `json:"force_browser"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	kind := model.JobKindRefresh
+	if json.ForceBrowser {
+		kind = model.JobKindRefreshBrowser
+	}
+
+	job, err := store.QueueArticleRefresh(id, userID.(int), kind)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else if err == store.ErrJobInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": "Article is already being extracted"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh article"})
+		}
+		return
+	}
+
+	queue.Notify()
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
 // AddTagToArticle handles adding a tag to an article.
 func AddTagToArticle(c *gin.Context) {
 	// 获取用户ID
@@ -156,7 +386,7 @@ func AddTagToArticle(c *gin.Context) {
 		return
 	}
 
-	err = store.AddTagToArticleByID(articleID, json.TagName, userID.(int))
+	tag, err := store.AddTagToArticleByID(articleID, json.TagName, userID.(int))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
@@ -166,11 +396,20 @@ func AddTagToArticle(c *gin.Context) {
 		return
 	}
 
+	events.Publish(userID.(int), events.TagAdded, events.TagChange{ArticleID: articleID, Tag: tag})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Tag added successfully"})
 }
 
 // RemoveTagFromArticle handles removing a tag from an article.
 func RemoveTagFromArticle(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	idParam := c.Param("id")
 	articleID, err := strconv.Atoi(idParam)
 	if err != nil {
@@ -185,7 +424,7 @@ func RemoveTagFromArticle(c *gin.Context) {
 		return
 	}
 
-	err = store.RemoveTagFromArticle(articleID, tagID)
+	tag, err := store.RemoveTagFromArticle(articleID, tagID, userID.(int))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Article or tag not found"})
@@ -195,6 +434,8 @@ func RemoveTagFromArticle(c *gin.Context) {
 		return
 	}
 
+	events.Publish(userID.(int), events.TagRemoved, events.TagChange{ArticleID: articleID, Tag: tag})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Tag removed successfully"})
 }
 
@@ -224,5 +465,7 @@ func DeleteArticle(c *gin.Context) {
 		return
 	}
 
+	events.Publish(userID.(int), events.ArticleDeleted, events.ArticleRef{ArticleID: id})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Article deleted successfully"})
 }
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"read-it-later/backend/assets"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAsset handles serving a stored asset, such as an article image, to a
+// user whose articles refer to it. Assets never change, so clients may cache
+// them indefinitely.
+func GetAsset(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	hash := c.Param("hash")
+	if !assets.IsHash(hash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asset"})
+		return
+	}
+
+	asset, err := store.GetAssetForUser(hash, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve asset"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	// SVG 图片可能包含脚本，直接打开时禁止执行
+	serveAsset(c, asset, "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+}
+
+// serveAsset responds with a stored asset under the given content security
+// policy. Compressed assets are sent as they are stored to clients accepting
+// their encoding and decompressed for other clients.
+func serveAsset(c *gin.Context, asset model.Asset, policy string) {
+	file, err := assets.Open(asset.Hash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read asset"})
+		}
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", asset.MediaType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", policy)
+
+	if asset.Encoding != "" {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsEncoding(c.Request, asset.Encoding) {
+			reader, err := gzip.NewReader(file)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read asset"})
+				return
+			}
+			defer reader.Close()
+
+			c.Status(http.StatusOK)
+			if _, err := io.Copy(c.Writer, reader); err != nil {
+				log.Printf("Error sending asset %s: %v", asset.Hash, err)
+			}
+			return
+		}
+		c.Header("Content-Encoding", asset.Encoding)
+	}
+
+	c.Header("ETag", `"`+asset.Hash+`"`)
+	// ServeContent answers conditional and range requests
+	http.ServeContent(c.Writer, c.Request, "", asset.CreatedAt, file)
+}
+
+// acceptsEncoding reports whether a request accepts a content encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(name), encoding) || strings.TrimSpace(name) == "*" {
+			return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// GetArticleSnapshot handles serving the self-contained HTML snapshot captured
+// of an article's page. The snapshot is shown in the browser, with scripts
+// blocked, or downloaded as a file with ?download=true.
+func GetArticleSnapshot(c *gin.Context) {
+	// 快照中的资源都已内联为 data URI，禁止加载外部资源和执行脚本
+	serveArticleCapture(c, model.AssetSnapshot, "Snapshot", ".html", c.Query("download") == "true",
+		"default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; font-src data:; sandbox")
+}
+
+// GetArticlePDF handles serving the PDF print of an article's page, shown in
+// the browser or downloaded as a file with ?download=true.
+func GetArticlePDF(c *gin.Context) {
+	// 浏览器的 PDF 阅读器在 sandbox 策略下无法打开，这里只禁止加载外部资源
+	serveArticleCapture(c, model.AssetPDF, "PDF", ".pdf", c.Query("download") == "true", "default-src 'none'; style-src 'unsafe-inline'")
+}
+
+// GetArticleScreenshot handles serving the full-page screenshot of an
+// article's page, or its thumbnail with ?size=thumbnail.
+func GetArticleScreenshot(c *gin.Context) {
+	kind := model.AssetScreenshot
+	switch c.Query("size") {
+	case "", "full":
+	case "thumbnail":
+		kind = model.AssetThumbnail
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size, expected full or thumbnail"})
+		return
+	}
+	serveArticleCapture(c, kind, "Screenshot", ".jpg", c.Query("download") == "true", "default-src 'none'; sandbox")
+}
+
+// serveArticleCapture responds with a capture of an article's page stored as
+// an asset of the given kind, as a file attachment if download is true. name
+// is used in errors and ext in the name of downloaded files.
+func serveArticleCapture(c *gin.Context, kind string, name string, ext string, download bool, policy string) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	article, err := store.GetArticleByID(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article"})
+		}
+		return
+	}
+
+	captures, err := store.GetArticleAssets(article.ID, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve capture"})
+		return
+	}
+	if len(captures) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found"})
+		return
+	}
+
+	if download {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": articleFilename(article, ext),
+		}))
+	}
+
+	capture := captures[0]
+	if capture.MediaType == "text/html" {
+		capture.MediaType = "text/html; charset=utf-8"
+	}
+	// 重新保存后内容会变化，每次都需要用 ETag 验证缓存
+	c.Header("Cache-Control", "private, no-cache")
+	serveAsset(c, capture, policy)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"read-it-later/backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogTokenResponse returns the response describing a catalog token, with
+// the catalog URL to give to e-readers.
+func catalogTokenResponse(c *gin.Context, token string) gin.H {
+	if token == "" {
+		return gin.H{"token": nil, "catalog_url": nil}
+	}
+	return gin.H{"token": token, "catalog_url": baseURL(c) + opdsRoot + "?token=" + token}
+}
+
+// GetCatalogToken handles retrieving the user's OPDS catalog token and the
+// catalog URL carrying it, if they have one.
+func GetCatalogToken(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token, err := store.GetCatalogToken(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve catalog token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, catalogTokenResponse(c, token))
+}
+
+// RotateCatalogToken handles creating the user's OPDS catalog token, or
+// replacing it so URLs carrying the previous one stop working.
+func RotateCatalogToken(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token, err := store.RotateCatalogToken(userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate catalog token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, catalogTokenResponse(c, token))
+}
+
+// RevokeCatalogToken handles removing the user's OPDS catalog token. E-readers
+// can still sign in with the username and password.
+func RevokeCatalogToken(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := store.RevokeCatalogToken(userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke catalog token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Catalog token revoked successfully"})
+}
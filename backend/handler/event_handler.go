@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"read-it-later/backend/events"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval keeps idle event streams open through proxies that close
+// quiet connections.
+const heartbeatInterval = 15 * time.Second
+
+// StreamEvents handles streaming the authenticated user's events as
+// Server-Sent Events.
+func StreamEvents(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ch, unsubscribe := events.Subscribe(userID.(int))
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	// Send a comment right away so clients know the stream is open
+	io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-ch:
+			c.SSEvent(event.Type, event.Data)
+			return true
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
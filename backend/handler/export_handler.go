@@ -0,0 +1,476 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"read-it-later/backend/assets"
+	"read-it-later/backend/epub"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBookArticles limits the number of articles in one EPUB export.
+const maxBookArticles = 200
+
+// errTooManyArticles stops collecting articles for a book past maxBookArticles.
+var errTooManyArticles = errors.New("too many articles")
+
+// ExportEPUB handles exporting the articles matching the list filters (tags,
+// state, favorite, domain and from/to dates) as an EPUB book, one chapter per
+// article, oldest first. ?tag= is accepted as a shorthand for a single tag.
+func ExportEPUB(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	opts, errMsg := parseListOptions(c, false)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		opts.Filter.Tags = append(opts.Filter.Tags, tag)
+	}
+
+	var articles []model.Article
+	err := store.EachArticle(userID.(int), opts.Filter, func(article model.Article) error {
+		if len(articles) == maxBookArticles {
+			return errTooManyArticles
+		}
+		articles = append(articles, article)
+		return nil
+	})
+	if err != nil {
+		if err == errTooManyArticles {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many articles, an export holds at most %d", maxBookArticles)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+		}
+		return
+	}
+
+	if len(articles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No articles match the filter"})
+		return
+	}
+
+	book := epub.New(bookTitle(opts.Filter), fetchBookImage)
+	if username, ok := c.Get("username"); ok {
+		book.Authors = []string{username.(string)}
+	}
+
+	for _, article := range articles {
+		if err := book.AddChapter(bookChapter(article)); err != nil {
+			log.Printf("Error adding article %d to EPUB: %v", article.ID, err)
+		}
+	}
+
+	writeEPUB(c, book, "read-it-later-"+time.Now().Format("2006-01-02")+".epub")
+}
+
+// writeEPUB responds with a book as a file attachment.
+func writeEPUB(c *gin.Context, book *epub.Book, filename string) {
+	c.Header("Content-Type", "application/epub+zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+	c.Status(http.StatusOK)
+
+	if err := book.Write(c.Writer); err != nil {
+		// 响应已经开始写入，无法返回JSON错误
+		log.Printf("Error writing EPUB: %v", err)
+	}
+}
+
+// articleEPUB builds a book holding a single article.
+func articleEPUB(article model.Article) (*epub.Book, error) {
+	book := epub.New(article.Title, fetchBookImage)
+	if article.Domain != "" {
+		book.Authors = []string{article.Domain}
+	}
+	book.Sources = []string{article.URL}
+
+	if err := book.AddChapter(bookChapter(article)); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// bookChapter converts an article to a book chapter.
+func bookChapter(article model.Article) epub.Chapter {
+	title := strings.TrimSpace(article.Title)
+	if title == "" {
+		title = article.URL
+	}
+
+	return epub.Chapter{
+		Title:  title,
+		Source: article.URL,
+		Site:   article.Domain,
+		Date:   article.CreatedAt,
+		HTML:   article.ContentHTML,
+		Text:   article.Content,
+	}
+}
+
+// bookTitle names a book after the filter its articles were selected with.
+func bookTitle(filter store.ArticleFilter) string {
+	var parts []string
+	if len(filter.Tags) > 0 {
+		parts = append(parts, strings.Join(filter.Tags, ", "))
+	}
+	switch filter.State {
+	case "unread":
+		parts = append(parts, "未读")
+	case "read":
+		parts = append(parts, "已读")
+	case "archived":
+		parts = append(parts, "已归档")
+	}
+	if filter.Domain != "" {
+		parts = append(parts, filter.Domain)
+	}
+
+	title := "Read It Later " + time.Now().Format("2006-01-02")
+	if len(parts) > 0 {
+		title += " · " + strings.Join(parts, " · ")
+	}
+	return title
+}
+
+// fetchBookImage returns an image for an EPUB export, reading stored assets
+// from disk and downloading remote images.
+func fetchBookImage(src string) ([]byte, string, error) {
+	if hash := strings.TrimPrefix(src, assets.URLPrefix); hash != src {
+		data, err := assets.Read(hash)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, assets.DetectMediaType(data), nil
+	}
+	return assets.FetchImage(src)
+}
+
+// libraryWriter streams the articles of a library export in one format.
+type libraryWriter interface {
+	Begin() error
+	WriteArticle(article model.ExportedArticle) error
+	End() error
+}
+
+// libraryFormats lists the formats of library exports with their content
+// types and file extensions.
+var libraryFormats = map[string]struct {
+	contentType string
+	ext         string
+	newWriter   func(w io.Writer) libraryWriter
+}{
+	"json": {"application/json; charset=utf-8", "json", newJSONLibraryWriter},
+	"csv":  {"text/csv; charset=utf-8", "csv", newCSVLibraryWriter},
+	"html": {"text/html; charset=utf-8", "html", newBookmarkLibraryWriter},
+}
+
+// ExportLibrary handles exporting all of the user's articles with their
+// tags, states, reading progress, highlights and timestamps. ?format=json
+// (the default) is the versioned schema of model.LibraryExport that another
+// instance can import; csv is a spreadsheet without the article content, and
+// html is a Netscape bookmark file that browsers can import. The export is
+// streamed, so libraries of any size are not held in memory.
+func ExportLibrary(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	format, ok := libraryFormats[c.DefaultQuery("format", "json")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, must be json, csv or html"})
+		return
+	}
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "read-it-later-" + time.Now().Format("2006-01-02") + "." + format.ext,
+	}))
+	c.Status(http.StatusOK)
+
+	w := format.newWriter(c.Writer)
+	err := w.Begin()
+	if err == nil {
+		err = store.EachArticle(userID.(int), store.ArticleFilter{}, func(article model.Article) error {
+			exported, err := exportedArticle(article)
+			if err != nil {
+				return err
+			}
+			return w.WriteArticle(exported)
+		})
+	}
+	if err == nil {
+		err = w.End()
+	}
+	if err != nil {
+		// 响应已经开始写入，无法返回JSON错误
+		log.Printf("Error exporting library of user %d: %v", userID, err)
+	}
+}
+
+// imageSources returns a replacer pointing the local copies of an article's
+// images back to their original URLs, for content read outside this server.
+func imageSources(articleID int) (*strings.Replacer, error) {
+	images, err := store.GetArticleAssets(articleID, model.AssetImage)
+	if err != nil {
+		return nil, err
+	}
+	return sourceReplacer(images), nil
+}
+
+// sourceReplacer returns a replacer pointing the local copies of images back
+// to their original URLs.
+func sourceReplacer(images []model.Asset) *strings.Replacer {
+	var replacements []string
+	for _, image := range images {
+		if image.SourceURL != "" {
+			replacements = append(replacements, assets.URL(image.Hash), image.SourceURL)
+		}
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// exportedArticle converts an article to the export schema, loading its
+// highlights. Stored images are replaced by their original URLs so the
+// export does not depend on this instance.
+func exportedArticle(article model.Article) (model.ExportedArticle, error) {
+	highlights, err := store.GetHighlightsForArticle(article.ID, article.UserID)
+	if err != nil {
+		return model.ExportedArticle{}, err
+	}
+	sources, err := imageSources(article.ID)
+	if err != nil {
+		return model.ExportedArticle{}, err
+	}
+
+	exported := model.ExportedArticle{
+		URL:         article.URL,
+		Title:       article.Title,
+		Excerpt:     article.Excerpt,
+		ImageURL:    sources.Replace(article.ImageURL),
+		Domain:      article.Domain,
+		Content:     article.Content,
+		ContentHTML: sources.Replace(article.ContentHTML),
+		Markdown:    sources.Replace(article.Markdown),
+		ReadingTime: article.ReadingTime,
+		Status:      article.Status,
+		CreatedAt:   article.CreatedAt,
+		RefreshedAt: article.RefreshedAt,
+		IsRead:      article.IsRead,
+		ReadAt:      article.ReadAt,
+		IsArchived:  article.IsArchived,
+		ArchivedAt:  article.ArchivedAt,
+		IsFavorite:  article.IsFavorite,
+		FavoritedAt: article.FavoritedAt,
+		Tags:        make([]string, len(article.Tags)),
+		Highlights:  make([]model.ExportedHighlight, len(highlights)),
+	}
+	for i, tag := range article.Tags {
+		exported.Tags[i] = tag.Name
+	}
+	if p := article.Progress; p != nil {
+		exported.Progress = &model.ExportedProgress{Percentage: p.Percentage, Offset: p.Offset, UpdatedAt: p.UpdatedAt}
+	}
+	for i, h := range highlights {
+		exported.Highlights[i] = model.ExportedHighlight{
+			StartOffset: h.StartOffset,
+			EndOffset:   h.EndOffset,
+			Quote:       h.Quote,
+			Prefix:      h.Prefix,
+			Suffix:      h.Suffix,
+			Note:        h.Note,
+			Color:       h.Color,
+			CreatedAt:   h.CreatedAt,
+			UpdatedAt:   h.UpdatedAt,
+		}
+	}
+	return exported, nil
+}
+
+// jsonLibraryWriter writes a model.LibraryExport one article at a time.
+type jsonLibraryWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONLibraryWriter(w io.Writer) libraryWriter {
+	return &jsonLibraryWriter{w: bufio.NewWriter(w)}
+}
+
+func (j *jsonLibraryWriter) Begin() error {
+	header, err := json.Marshal(model.LibraryExport{
+		Format:     model.ExportFormat,
+		Version:    model.ExportVersion,
+		ExportedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	// 去掉结尾的 "articles":null}，逐篇写入文章
+	header = bytes.TrimSuffix(header, []byte(`null}`))
+	_, err = j.w.Write(append(header, "[\n"...))
+	return err
+}
+
+func (j *jsonLibraryWriter) WriteArticle(article model.ExportedArticle) error {
+	data, err := json.Marshal(article)
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		j.w.WriteString(",\n")
+	}
+	j.count++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonLibraryWriter) End() error {
+	j.w.WriteString("\n]}\n")
+	return j.w.Flush()
+}
+
+// csvColumns are the columns of CSV library exports.
+var csvColumns = []string{
+	"url", "title", "domain", "tags", "status", "created_at", "is_read", "read_at", "is_archived", "archived_at",
+	"is_favorite", "favorited_at", "reading_time", "progress", "excerpt", "highlights",
+}
+
+// csvLibraryWriter writes articles as CSV rows. Tags are separated by "|",
+// and highlights by blank lines with their notes after "— ".
+type csvLibraryWriter struct {
+	w *csv.Writer
+}
+
+func newCSVLibraryWriter(w io.Writer) libraryWriter {
+	return &csvLibraryWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvLibraryWriter) Begin() error {
+	return c.w.Write(csvColumns)
+}
+
+func (c *csvLibraryWriter) WriteArticle(article model.ExportedArticle) error {
+	var highlights []string
+	for _, h := range article.Highlights {
+		text := h.Quote
+		if h.Note != "" {
+			text += "\n— " + h.Note
+		}
+		highlights = append(highlights, text)
+	}
+	progress := ""
+	if article.Progress != nil {
+		progress = strconv.FormatFloat(article.Progress.Percentage, 'f', -1, 64)
+	}
+
+	return c.w.Write([]string{
+		article.URL,
+		csvText(article.Title),
+		article.Domain,
+		csvText(strings.Join(article.Tags, "|")),
+		article.Status,
+		csvTime(&article.CreatedAt),
+		strconv.FormatBool(article.IsRead),
+		csvTime(article.ReadAt),
+		strconv.FormatBool(article.IsArchived),
+		csvTime(article.ArchivedAt),
+		strconv.FormatBool(article.IsFavorite),
+		csvTime(article.FavoritedAt),
+		strconv.Itoa(article.ReadingTime),
+		progress,
+		csvText(article.Excerpt),
+		csvText(strings.Join(highlights, "\n\n")),
+	})
+}
+
+func (c *csvLibraryWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvText keeps spreadsheets from running text that starts like a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvTime formats an optional time for CSV exports.
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// bookmarkLibraryWriter writes articles as a Netscape bookmark file, with
+// their tags in the TAGS attribute and their excerpts as descriptions.
+type bookmarkLibraryWriter struct {
+	w *bufio.Writer
+}
+
+func newBookmarkLibraryWriter(w io.Writer) libraryWriter {
+	return &bookmarkLibraryWriter{w: bufio.NewWriter(w)}
+}
+
+func (b *bookmarkLibraryWriter) Begin() error {
+	_, err := b.w.WriteString(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Read It Later</TITLE>
+<H1>Read It Later</H1>
+<DL><p>
+`)
+	return err
+}
+
+func (b *bookmarkLibraryWriter) WriteArticle(article model.ExportedArticle) error {
+	title := article.Title
+	if title == "" {
+		title = article.URL
+	}
+
+	fmt.Fprintf(b.w, `    <DT><A HREF="%s" ADD_DATE="%d"`, html.EscapeString(article.URL), article.CreatedAt.Unix())
+	if len(article.Tags) > 0 {
+		fmt.Fprintf(b.w, ` TAGS="%s"`, html.EscapeString(strings.Join(article.Tags, ",")))
+	}
+	fmt.Fprintf(b.w, ">%s</A>\n", html.EscapeString(title))
+	if article.Excerpt != "" {
+		fmt.Fprintf(b.w, "    <DD>%s\n", html.EscapeString(article.Excerpt))
+	}
+	return nil
+}
+
+func (b *bookmarkLibraryWriter) End() error {
+	b.w.WriteString("</DL><p>\n")
+	return b.w.Flush()
+}
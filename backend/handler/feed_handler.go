@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"read-it-later/backend/feeds"
+	"read-it-later/backend/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFeedEntries limits the number of entries listed for a feed.
+const maxFeedEntries = 200
+
+// feedTags trims tags and drops empty and repeated ones. It never returns nil,
+// so feeds without tags are stored with an empty list.
+func feedTags(tags []string) []string {
+	cleaned := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned
+}
+
+// CreateFeed handles subscribing to an RSS, Atom or JSON feed, or to the feed
+// advertised by a web page. New entries are saved with the given tags; the
+// newest "backfill" entries already in the feed are saved right away.
+func CreateFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var json struct {
+		URL      string   `json:"url" binding:"required"`
+		Tags     []string `json:"tags"`
+		Backfill int      `json:"backfill"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedURL, err := url.ParseRequestURI(json.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
+		return
+	}
+	if json.Backfill < 0 || json.Backfill > feeds.MaxBackfill {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("backfill must be between 0 and %d", feeds.MaxBackfill)})
+		return
+	}
+
+	feed, err := feeds.Subscribe(userID.(int), json.URL, feedTags(json.Tags), json.Backfill)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrFeedExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Already subscribed to this feed"})
+		case errors.Is(err, feeds.ErrNoFeed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No RSS, Atom or JSON feed found at this URL"})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch feed: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+// GetFeeds handles listing the user's feeds.
+func GetFeeds(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := store.GetFeedsForUser(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feeds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetFeed handles retrieving one of the user's feeds with its poll status.
+func GetFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	feed, err := store.GetFeedByID(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// UpdateFeed handles renaming a feed or changing the tags its new entries are
+// saved with. Omitted fields are left unchanged.
+func UpdateFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	var json struct {
+		Title *string   `json:"title"`
+		Tags  *[]string `json:"tags"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tags []string
+	if json.Tags != nil {
+		tags = feedTags(*json.Tags)
+	}
+	if json.Title != nil {
+		title := strings.TrimSpace(*json.Title)
+		json.Title = &title
+	}
+
+	feed, err := store.UpdateFeed(id, userID.(int), json.Title, tags)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// DeleteFeed handles unsubscribing from a feed. Articles saved from it are
+// kept.
+func DeleteFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	if err := store.DeleteFeed(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete feed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feed deleted successfully"})
+}
+
+// RefreshFeed handles polling a feed now instead of at its next scheduled
+// time. The feed is polled in the background.
+func RefreshFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	if err := store.ScheduleFeedPoll(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh feed"})
+		}
+		return
+	}
+
+	feeds.Wake()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Feed refresh scheduled"})
+}
+
+// GetFeedEntries handles listing the latest entries seen in a feed with the
+// articles they were saved as.
+func GetFeedEntries(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	if _, err := store.GetFeedByID(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feed"})
+		}
+		return
+	}
+
+	entries, err := store.GetFeedEntries(id, userID.(int), maxFeedEntries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feed entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
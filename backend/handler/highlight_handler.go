@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHighlights handles listing all highlights of an article.
+func GetHighlights(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	highlights, err := store.GetHighlightsForArticle(articleID, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve highlights"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, highlights)
+}
+
+// AddHighlight handles creating a highlight on an article.
+func AddHighlight(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	var json struct {
+		StartOffset int    `json:"start_offset"`
+		EndOffset   int    `json:"end_offset"`
+		Quote       string `json:"quote"`
+		Prefix      string `json:"prefix"`
+		Suffix      string `json:"suffix"`
+		Note        string `json:"note"`
+		Color       string `json:"color"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if json.Color == "" {
+		json.Color = "yellow"
+	}
+	if !store.IsValidHighlightColor(json.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid highlight color"})
+		return
+	}
+
+	highlight, err := store.CreateHighlight(model.Highlight{
+		ArticleID:   articleID,
+		StartOffset: json.StartOffset,
+		EndOffset:   json.EndOffset,
+		Quote:       json.Quote,
+		Prefix:      json.Prefix,
+		Suffix:      json.Suffix,
+		Note:        json.Note,
+		Color:       json.Color,
+	}, userID.(int))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		case store.ErrInvalidHighlight:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Highlight does not match article content"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save highlight"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, highlight)
+}
+
+// UpdateHighlight handles changing the note or color of a highlight.
+func UpdateHighlight(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	highlightIDParam := c.Param("highlightId")
+	highlightID, err := strconv.Atoi(highlightIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid highlight ID"})
+		return
+	}
+
+	var json struct {
+		Note  *string `json:"note"`
+		Color *string `json:"color"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if json.Color != nil && !store.IsValidHighlightColor(*json.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid highlight color"})
+		return
+	}
+
+	highlight, err := store.UpdateHighlight(highlightID, articleID, userID.(int), json.Note, json.Color)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Highlight not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update highlight"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+// DeleteHighlight handles deleting a highlight from an article.
+func DeleteHighlight(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	highlightIDParam := c.Param("highlightId")
+	highlightID, err := strconv.Atoi(highlightIDParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid highlight ID"})
+		return
+	}
+
+	err = store.DeleteHighlight(highlightID, articleID, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Highlight not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete highlight"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Highlight deleted successfully"})
+}
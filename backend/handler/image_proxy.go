@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"read-it-later/backend/assets"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -46,22 +47,12 @@ func ProxyImage(c *gin.Context) {
 
 	// 创建HTTP客户端
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", imageURL, nil)
+	req, err := assets.NewImageRequest(parsedURL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
 		return
 	}
 
-	// 设置合适的请求头，模拟来自微信的请求
-	if strings.Contains(parsedURL.Host, "mmbiz.qpic.cn") ||
-		strings.Contains(parsedURL.Host, "wx.qpic.cn") {
-		req.Header.Set("Referer", "https://mp.weixin.qq.com/")
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 MicroMessenger/6.7.3.9001")
-	} else {
-		// 对其他域名使用通用请求头
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	}
-
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
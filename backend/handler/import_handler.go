@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"read-it-later/backend/importer"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportSize limits the size of an upload: a Read It Later backup, the
+	// largest export accepted, and the other form fields.
+	maxImportSize = importer.MaxBackupSize + 1<<20
+	// importFormMemory is the part of an upload kept in memory; the rest is
+	// buffered in temporary files.
+	importFormMemory = 32 << 20
+)
+
+// CreateImport handles uploading an export of another read-later service as
+// the multipart field "file". The format is read from the "format" field or
+// detected from the content, and for bookmark files the "folder_tags" field
+// says how folders become tags. Articles are imported in the background; the
+// returned import reports the progress.
+func CreateImport(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 先限制请求体大小，再读取任何表单字段，否则解析表单时会读入整个请求体
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	if err := c.Request.ParseMultipartForm(importFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Export file is too large, the limit is %d MB", importer.MaxBackupSize>>20)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An export file is required in the file field"})
+		}
+		return
+	}
+
+	format := c.PostForm("format")
+	if format != "" && !importer.IsValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import format"})
+		return
+	}
+
+	folderTags := c.DefaultPostForm("folder_tags", importer.FolderTagsEach)
+	if folderTags != importer.FolderTagsEach && folderTags != importer.FolderTagsPath && folderTags != importer.FolderTagsNone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder_tags, must be each, path or none"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An export file is required in the file field"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	imp, err := importer.Start(userID.(int), format, filepath.Base(header.Filename), file, importer.Options{FolderTags: folderTags})
+	if err != nil {
+		var readErr *importer.ReadError
+		if errors.Is(err, importer.ErrUnknownFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unrecognized export format, please specify the format"})
+		} else if errors.Is(err, importer.ErrTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Export file is too large, the limit is %d MB for exports of other services", importer.MaxExportSize>>20)})
+		} else if errors.Is(err, importer.ErrNoArticles) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No articles found in the export"})
+		} else if errors.As(err, &readErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read export: " + err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, imp)
+}
+
+// GetImports handles listing the user's imports, newest first.
+func GetImports(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	imports, err := store.GetImportsForUser(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, imports)
+}
+
+// GetImport handles checking the progress of an import.
+func GetImport(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	imp, err := store.GetImportByID(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve import"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// GetImportItems handles listing the items of an import with their outcome,
+// optionally only those with the status given by ?status=.
+func GetImportItems(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", model.ImportItemPending, model.ImportItemImported, model.ImportItemSkipped, model.ImportItemFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+		return
+	}
+
+	if _, err := store.GetImportByID(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve import"})
+		}
+		return
+	}
+
+	items, err := store.GetImportItems(id, userID.(int), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve import items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
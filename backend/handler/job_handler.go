@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"read-it-later/backend/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJob handles checking the status of a background job.
+func GetJob(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := store.GetJobByID(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/model"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFilenameLength limits the length, in characters, of download file names.
+const maxFilenameLength = 80
+
+// writeMarkdown responds with an article as a Markdown document, optionally
+// as a file attachment.
+func writeMarkdown(c *gin.Context, article model.Article, download bool) {
+	if download {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": articleFilename(article, ".md"),
+		}))
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(markdownDocument(article)))
+}
+
+// markdownDocument renders an article as Markdown with YAML front matter
+// holding its metadata, as expected by most notes tools.
+func markdownDocument(article model.Article) string {
+	body := article.Markdown
+	if body == "" {
+		// Articles extracted before Markdown was stored
+		if article.ContentHTML != "" {
+			body = extractor.HTMLToMarkdown(article.ContentHTML)
+		} else {
+			body = article.Content
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	// JSON strings are valid YAML scalars and need no further escaping
+	fmt.Fprintf(&b, "title: %s\n", yamlString(article.Title))
+	fmt.Fprintf(&b, "url: %s\n", yamlString(article.URL))
+	fmt.Fprintf(&b, "saved: %s\n", article.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"))
+	if len(article.Tags) > 0 {
+		names := make([]string, len(article.Tags))
+		for i, tag := range article.Tags {
+			names[i] = yamlString(tag.Name)
+		}
+		fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(names, ", "))
+	}
+	b.WriteString("---\n\n")
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSpace(article.Title))
+	b.WriteString(strings.TrimSpace(body))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar.
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// articleFilename builds a download file name with the given extension from
+// an article's title, falling back to its ID.
+func articleFilename(article model.Article, ext string) string {
+	var b strings.Builder
+	length := 0
+	lastDash := true
+	for _, r := range article.Title {
+		if length >= maxFilenameLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			b.WriteRune('-')
+			lastDash = true
+		}
+		length++
+	}
+
+	name := strings.Trim(b.String(), "-")
+	if name == "" {
+		name = fmt.Sprintf("article-%d", article.ID)
+	}
+	return name + ext
+}
@@ -0,0 +1,383 @@
+package handler
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"read-it-later/backend/assets"
+	"read-it-later/backend/atom"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// opdsPageSize is the number of articles per page of an OPDS acquisition feed.
+const opdsPageSize = 50
+
+// opdsRoot is the path of the OPDS catalog root.
+const opdsRoot = "/api/opds"
+
+// OPDSCatalog handles the root navigation feed of the OPDS catalog.
+func OPDSCatalog(c *gin.Context) {
+	feed := atom.NewFeed("urn:read-it-later:opds", "Read It Later", time.Now())
+	feed.Links = opdsFeedLinks(c, opdsRoot, atom.NavigationFeedType)
+
+	feed.Entries = []atom.Entry{
+		opdsNavigationEntry(c, "urn:read-it-later:opds:unread", "未读文章", "按保存时间从新到旧排列的未读文章",
+			opdsRoot+"/unread", atom.AcquisitionFeedType, atom.RelSortNew),
+		opdsNavigationEntry(c, "urn:read-it-later:opds:tags", "标签", "按标签浏览文章",
+			opdsRoot+"/tags", atom.NavigationFeedType, atom.RelSubsection),
+	}
+
+	writeFeed(c, feed, atom.NavigationFeedType)
+}
+
+// OPDSUnread handles the acquisition feed of the user's unread articles.
+func OPDSUnread(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	filter := store.ArticleFilter{State: "unread"}
+	writeAcquisitionFeed(c, userID.(int), filter, "urn:read-it-later:opds:unread", "未读文章", opdsRoot+"/unread")
+}
+
+// OPDSTags handles the navigation feed listing the user's tags.
+func OPDSTags(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tags, err := store.GetTagsForUser(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	feed := atom.NewFeed("urn:read-it-later:opds:tags", "标签", time.Now())
+	feed.Links = opdsFeedLinks(c, opdsRoot+"/tags", atom.NavigationFeedType)
+
+	for _, tag := range tags {
+		entry := opdsNavigationEntry(c, "urn:read-it-later:opds:tag:"+strconv.Itoa(tag.ID), tag.Name,
+			strconv.Itoa(tag.ArticleCount)+" 篇文章", opdsRoot+"/tags/"+strconv.Itoa(tag.ID),
+			atom.AcquisitionFeedType, atom.RelSubsection)
+		entry.Links[0].Count = tag.ArticleCount
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	writeFeed(c, feed, atom.NavigationFeedType)
+}
+
+// OPDSTag handles the acquisition feed of the articles carrying a tag.
+func OPDSTag(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	tag, err := store.GetTagByID(tagID, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tag"})
+		}
+		return
+	}
+
+	filter := store.ArticleFilter{Tags: []string{tag.Name}}
+	writeAcquisitionFeed(c, userID.(int), filter, "urn:read-it-later:opds:tag:"+strconv.Itoa(tag.ID), tag.Name,
+		opdsRoot+"/tags/"+strconv.Itoa(tag.ID))
+}
+
+// OPDSArticleEPUB handles downloading an article as an EPUB book.
+func OPDSArticleEPUB(c *gin.Context) {
+	article, ok := opdsArticle(c)
+	if !ok {
+		return
+	}
+
+	book, err := articleEPUB(article)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build EPUB"})
+		return
+	}
+	writeEPUB(c, book, articleFilename(article, ".epub"))
+}
+
+// OPDSArticleHTML handles downloading an article as a standalone HTML page.
+func OPDSArticleHTML(c *gin.Context) {
+	article, ok := opdsArticle(c)
+	if !ok {
+		return
+	}
+
+	// 下载的页面脱离本服务器打开，图片需指向原始地址
+	if _, err := originalImages(&article); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article images"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": articleFilename(article, ".html"),
+	}))
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := articlePageTemplate.Execute(c.Writer, articlePage(article)); err != nil {
+		log.Printf("Error rendering article %d: %v", article.ID, err)
+	}
+}
+
+// opdsArticle loads the article an acquisition link points to, responding
+// with an error if it cannot be loaded.
+func opdsArticle(c *gin.Context) (model.Article, bool) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return model.Article{}, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return model.Article{}, false
+	}
+
+	article, err := store.GetArticleByID(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article"})
+		}
+		return model.Article{}, false
+	}
+	return article, true
+}
+
+// writeAcquisitionFeed responds with one page of the articles matching
+// filter, newest first, each with its download links.
+func writeAcquisitionFeed(c *gin.Context, userID int, filter store.ArticleFilter, id, title, feedPath string) {
+	opts := store.ListOptions{
+		Filter: filter,
+		Sort:   "created_at",
+		Order:  "desc",
+		Limit:  opdsPageSize,
+		Cursor: c.Query("cursor"),
+	}
+
+	page, err := store.ListArticles(userID, opts)
+	if err != nil {
+		if err == store.ErrInvalidCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+		}
+		return
+	}
+
+	feed := atom.NewFeed(id, title, time.Now())
+	feed.Links = opdsFeedLinks(c, feedPath, atom.AcquisitionFeedType)
+	feed.Links = append(feed.Links, atom.Link{Rel: atom.RelUp, Href: opdsHref(c, opdsRoot, nil), Type: atom.NavigationFeedType})
+	if page.NextCursor != "" {
+		feed.Links = append(feed.Links, atom.Link{
+			Rel:  atom.RelNext,
+			Href: opdsHref(c, feedPath, url.Values{"cursor": {page.NextCursor}}),
+			Type: atom.AcquisitionFeedType,
+		})
+	}
+
+	for _, article := range page.Articles {
+		imageType, err := originalImages(&article)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article images"})
+			return
+		}
+		feed.Entries = append(feed.Entries, opdsArticleEntry(c, article, imageType))
+	}
+
+	writeFeed(c, feed, atom.AcquisitionFeedType)
+}
+
+// originalImages points the stored images of an article back to their
+// original URLs, which e-readers can load without signing in to this server.
+// It returns the media type of the lead image, if it was stored.
+func originalImages(article *model.Article) (string, error) {
+	images, err := store.GetArticleAssets(article.ID, model.AssetImage)
+	if err != nil {
+		return "", err
+	}
+
+	var imageType string
+	for _, image := range images {
+		if assets.URL(image.Hash) == article.ImageURL {
+			imageType = image.MediaType
+		}
+	}
+
+	sources := sourceReplacer(images)
+	article.ImageURL = sources.Replace(article.ImageURL)
+	article.ContentHTML = sources.Replace(article.ContentHTML)
+	return imageType, nil
+}
+
+// opdsArticleEntry describes an article with links to download it as an
+// e-book or an HTML page. imageType is the media type of the lead image, if
+// known.
+func opdsArticleEntry(c *gin.Context, article model.Article, imageType string) atom.Entry {
+	updated := article.CreatedAt
+	if article.RefreshedAt != nil {
+		updated = *article.RefreshedAt
+	}
+	published := atom.Time(article.CreatedAt)
+
+	articlePath := opdsRoot + "/articles/" + strconv.Itoa(article.ID)
+	entry := atom.Entry{
+		ID:        "urn:read-it-later:article:" + strconv.Itoa(article.ID),
+		Title:     article.Title,
+		Updated:   atom.Time(updated),
+		Published: &published,
+		Issued:    article.CreatedAt.Format("2006-01-02"),
+		Source:    article.URL,
+		Links: []atom.Link{
+			{Rel: atom.RelAcquisition, Href: opdsHref(c, articlePath+"/epub", nil), Type: "application/epub+zip"},
+			{Rel: atom.RelAcquisition, Href: opdsHref(c, articlePath+"/html", nil), Type: "text/html"},
+			{Rel: atom.RelAlternate, Href: article.URL, Type: "text/html", Title: "原文"},
+		},
+	}
+	if article.Domain != "" {
+		entry.Authors = []atom.Person{{Name: article.Domain}}
+	}
+	if article.Excerpt != "" {
+		entry.Summary = &atom.Text{Type: "text", Body: article.Excerpt}
+	}
+	for _, tag := range article.Tags {
+		entry.Categories = append(entry.Categories, atom.Category{Term: tag.Name, Label: tag.Name})
+	}
+	// Images stored without their original URL cannot be loaded by e-readers
+	if article.ImageURL != "" && !strings.HasPrefix(article.ImageURL, assets.URLPrefix) {
+		if imageType == "" {
+			imageType = mime.TypeByExtension(path.Ext(article.ImageURL))
+		}
+		if imageType == "" {
+			imageType = "image/jpeg"
+		}
+		entry.Links = append(entry.Links,
+			atom.Link{Rel: atom.RelImage, Href: article.ImageURL, Type: imageType},
+			atom.Link{Rel: atom.RelThumbnail, Href: article.ImageURL, Type: imageType})
+	}
+	return entry
+}
+
+// opdsNavigationEntry describes a link to another catalog feed.
+func opdsNavigationEntry(c *gin.Context, id, title, content, feedPath, feedType, rel string) atom.Entry {
+	return atom.Entry{
+		ID:      id,
+		Title:   title,
+		Updated: atom.Time(time.Now()),
+		Content: &atom.Text{Type: "text", Body: content},
+		Links:   []atom.Link{{Rel: rel, Href: opdsHref(c, feedPath, nil), Type: feedType}},
+	}
+}
+
+// opdsFeedLinks returns the self and start links every catalog feed carries.
+func opdsFeedLinks(c *gin.Context, feedPath, feedType string) []atom.Link {
+	return []atom.Link{
+		{Rel: atom.RelSelf, Href: opdsHref(c, feedPath, nil), Type: feedType},
+		{Rel: atom.RelStart, Href: opdsHref(c, opdsRoot, nil), Type: atom.NavigationFeedType},
+	}
+}
+
+// opdsHref builds a catalog link. Clients that authenticated with a token in
+// the query string need it on every link they follow.
+func opdsHref(c *gin.Context, linkPath string, query url.Values) string {
+	if token := c.Query("token"); token != "" && c.GetHeader("Authorization") == "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("token", token)
+	}
+	if len(query) == 0 {
+		return linkPath
+	}
+	return linkPath + "?" + query.Encode()
+}
+
+// writeFeed responds with a catalog feed.
+func writeFeed(c *gin.Context, feed *atom.Feed, feedType string) {
+	c.Header("Content-Type", feedType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := feed.Write(c.Writer); err != nil {
+		log.Printf("Error writing feed: %v", err)
+	}
+}
+
+// articlePageData is the data rendered by articlePageTemplate.
+type articlePageData struct {
+	Title   string
+	URL     string
+	Domain  string
+	Saved   string
+	Content template.HTML
+	Text    string
+}
+
+// articlePage prepares an article for articlePageTemplate.
+func articlePage(article model.Article) articlePageData {
+	return articlePageData{
+		Title:  article.Title,
+		URL:    article.URL,
+		Domain: article.Domain,
+		Saved:  article.CreatedAt.Format("2006-01-02"),
+		// content_html is sanitized when the article is extracted
+		Content: template.HTML(article.ContentHTML),
+		Text:    article.Content,
+	}
+}
+
+// articlePageTemplate renders an article as a standalone HTML page for
+// e-readers, with a font stack suited to CJK text.
+var articlePageTemplate = template.Must(template.New("article").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 40em; margin: 0 auto; padding: 1em; line-height: 1.7; line-break: strict; font-family: serif, "Noto Serif CJK SC", "Source Han Serif SC", "Songti SC", "SimSun"; }
+img { max-width: 100%; height: auto; }
+pre { white-space: pre-wrap; }
+.meta { color: #666; font-size: 0.85em; }
+.text { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta"><a href="{{.URL}}">{{if .Domain}}{{.Domain}}{{else}}{{.URL}}{{end}}</a> · {{.Saved}}</p>
+{{if .Content}}{{.Content}}{{else}}<div class="text">{{.Text}}</div>{{end}}
+</body>
+</html>
+`))
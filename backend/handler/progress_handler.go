@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readThreshold is the reading percentage at which an article is
+// automatically marked as read.
+var readThreshold = 90.0
+
+// SetReadThreshold configures the reading percentage (0-100) at which an
+// article is automatically marked as read.
+func SetReadThreshold(percentage float64) {
+	readThreshold = percentage
+}
+
+// GetReadingProgress handles retrieving the reading progress of an article.
+func GetReadingProgress(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	progress, err := store.GetReadingProgress(articleID, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reading progress not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reading progress"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+// UpdateReadingProgress handles saving the reading progress of an article and
+// marks the article as read once the progress crosses the read threshold.
+func UpdateReadingProgress(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	idParam := c.Param("id")
+	articleID, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	var json struct {
+		Percentage *float64 `json:"percentage"`
+		Offset     *int     `json:"offset"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if json.Percentage == nil && json.Offset == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage or offset is required"})
+		return
+	}
+
+	article, err := store.GetArticleByID(articleID, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article"})
+		}
+		return
+	}
+
+	progress := model.ReadingProgress{ArticleID: articleID}
+	contentLength := utf8.RuneCountInString(article.Content)
+
+	if json.Offset != nil {
+		if *json.Offset < 0 || *json.Offset > contentLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Offset is out of range"})
+			return
+		}
+		progress.Offset = *json.Offset
+	}
+
+	if json.Percentage != nil {
+		if *json.Percentage < 0 || *json.Percentage > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage must be between 0 and 100"})
+			return
+		}
+		progress.Percentage = *json.Percentage
+		if json.Offset == nil {
+			// 仅提供百分比时，根据正文长度换算字符偏移量
+			progress.Offset = int(progress.Percentage / 100 * float64(contentLength))
+		}
+	} else if contentLength > 0 {
+		// 仅提供字符偏移量时，根据正文长度换算百分比
+		progress.Percentage = float64(progress.Offset) / float64(contentLength) * 100
+	}
+
+	savedProgress, err := store.SaveReadingProgress(progress, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reading progress"})
+		}
+		return
+	}
+
+	if !article.IsRead && savedProgress.Percentage >= readThreshold {
+		isRead := true
+		if _, err := store.UpdateArticleState(articleID, userID.(int), model.ArticleStateUpdate{IsRead: &isRead}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark article as read"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, savedProgress)
+}
@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"read-it-later/backend/atom"
+	"read-it-later/backend/model"
+	"read-it-later/backend/rss"
+	"read-it-later/backend/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// publishedRoot is the path of published feeds, which are read with the
+	// token in their URL instead of the Authorization header.
+	publishedRoot = "/api/published"
+	// maxPublishedArticles limits the number of articles in a published feed.
+	maxPublishedArticles = 50
+)
+
+// publishedStates are the reading states a published feed can be limited to.
+var publishedStates = map[string]bool{"": true, "unread": true, "read": true, "archived": true}
+
+// baseURL returns the scheme and host the request was made to, so feed
+// readers get absolute links.
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	host := c.GetHeader("X-Forwarded-Host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	return scheme + "://" + host
+}
+
+// withFeedURLs fills in the addresses of a published feed.
+func withFeedURLs(c *gin.Context, feed model.PublishedFeed) model.PublishedFeed {
+	feedPath := baseURL(c) + publishedRoot + "/" + feed.Token
+	feed.AtomURL = feedPath + "/atom"
+	feed.RSSURL = feedPath + "/rss"
+	return feed
+}
+
+// CreatePublishedFeed handles publishing a feed of the user's articles,
+// optionally only those with a tag or in a reading state. The response holds
+// the feed's URLs, which include its token.
+func CreatePublishedFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var json struct {
+		Title string `json:"title"`
+		TagID *int   `json:"tag_id"`
+		State string `json:"state"`
+	}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !publishedStates[json.State] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be unread, read or archived"})
+		return
+	}
+
+	title := strings.TrimSpace(json.Title)
+	if title == "" {
+		title = fmt.Sprintf("%s 的稍后读", c.GetString("username"))
+	}
+	if json.TagID != nil {
+		tag, err := store.GetTagByID(*json.TagID, userID.(int))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tag"})
+			}
+			return
+		}
+		if strings.TrimSpace(json.Title) == "" {
+			title += " · " + tag.Name
+		}
+	}
+
+	feed, err := store.CreatePublishedFeed(model.PublishedFeed{
+		UserID: userID.(int),
+		Title:  title,
+		TagID:  json.TagID,
+		State:  json.State,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, withFeedURLs(c, feed))
+}
+
+// GetPublishedFeeds handles listing the user's published feeds with their
+// URLs.
+func GetPublishedFeeds(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := store.GetPublishedFeedsForUser(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve published feeds"})
+		return
+	}
+
+	for i := range list {
+		list[i] = withFeedURLs(c, list[i])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RotatePublishedFeedToken handles replacing the token of a published feed,
+// such as after its URL leaked. Feed readers must be given the new URLs.
+func RotatePublishedFeedToken(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid published feed ID"})
+		return
+	}
+
+	feed, err := store.RotatePublishedFeedToken(id, userID.(int))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Published feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, withFeedURLs(c, feed))
+}
+
+// DeletePublishedFeed handles unpublishing a feed. Its URLs stop working.
+func DeletePublishedFeed(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid published feed ID"})
+		return
+	}
+
+	if err := store.DeletePublishedFeed(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Published feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete published feed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Published feed deleted successfully"})
+}
+
+// publishedArticle is an article of a published feed, with its content
+// pointing to the original images.
+type publishedArticle struct {
+	model.Article
+	Updated time.Time
+}
+
+// loadPublishedFeed looks up the published feed whose token is in the URL and
+// loads its latest articles. It responds with an error and returns false if
+// the token is unknown.
+func loadPublishedFeed(c *gin.Context) (model.PublishedFeed, *model.User, []publishedArticle, bool) {
+	feed, err := store.GetPublishedFeedByToken(c.Param("token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Published feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve published feed"})
+		}
+		return model.PublishedFeed{}, nil, nil, false
+	}
+
+	user, err := store.GetUserByID(feed.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve published feed"})
+		return model.PublishedFeed{}, nil, nil, false
+	}
+
+	filter := store.ArticleFilter{State: feed.State}
+	if feed.TagID != nil {
+		filter.Tags = []string{feed.TagName}
+	}
+	articles, err := store.GetLatestArticles(feed.UserID, filter, maxPublishedArticles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+		return model.PublishedFeed{}, nil, nil, false
+	}
+
+	published := make([]publishedArticle, len(articles))
+	for i, article := range articles {
+		sources, err := imageSources(article.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve articles"})
+			return model.PublishedFeed{}, nil, nil, false
+		}
+		article.ImageURL = sources.Replace(article.ImageURL)
+		article.ContentHTML = sources.Replace(article.ContentHTML)
+
+		updated := article.CreatedAt
+		if article.RefreshedAt != nil {
+			updated = *article.RefreshedAt
+		}
+		published[i] = publishedArticle{Article: article, Updated: updated}
+	}
+	return feed, user, published, true
+}
+
+// feedUpdated returns when the newest of the articles was last changed, or
+// when the feed was published if it has none.
+func feedUpdated(feed model.PublishedFeed, articles []publishedArticle) time.Time {
+	updated := feed.CreatedAt
+	for _, article := range articles {
+		if article.Updated.After(updated) {
+			updated = article.Updated
+		}
+	}
+	return updated
+}
+
+// PublishedFeedAtom handles reading a published feed as Atom, with the full
+// content of its articles.
+func PublishedFeedAtom(c *gin.Context) {
+	feed, user, articles, ok := loadPublishedFeed(c)
+	if !ok {
+		return
+	}
+
+	doc := &atom.Feed{
+		XMLNS:   atom.Namespace,
+		ID:      "urn:read-it-later:published:" + strconv.Itoa(feed.ID),
+		Title:   feed.Title,
+		Updated: atom.Time(feedUpdated(feed, articles)),
+		Author:  &atom.Person{Name: user.Username},
+		Links: []atom.Link{
+			{Rel: atom.RelSelf, Href: withFeedURLs(c, feed).AtomURL, Type: atom.ContentType},
+		},
+		Entries: []atom.Entry{},
+	}
+	for _, article := range articles {
+		published := atom.Time(article.CreatedAt)
+		entry := atom.Entry{
+			ID:        "urn:read-it-later:article:" + strconv.Itoa(article.ID),
+			Title:     article.Title,
+			Updated:   atom.Time(article.Updated),
+			Published: &published,
+			Links:     []atom.Link{{Rel: atom.RelAlternate, Href: article.URL, Type: "text/html"}},
+		}
+		if article.Domain != "" {
+			entry.Authors = []atom.Person{{Name: article.Domain}}
+		}
+		if article.Excerpt != "" {
+			entry.Summary = &atom.Text{Type: "text", Body: article.Excerpt}
+		}
+		if article.ContentHTML != "" {
+			entry.Content = &atom.Text{Type: "html", Body: article.ContentHTML}
+		} else if article.Content != "" {
+			entry.Content = &atom.Text{Type: "text", Body: article.Content}
+		}
+		for _, tag := range article.Tags {
+			entry.Categories = append(entry.Categories, atom.Category{Term: tag.Name, Label: tag.Name})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		log.Printf("Error writing published feed %d: %v", feed.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write feed"})
+		return
+	}
+	servePublishedFeed(c, buf.Bytes(), atom.ContentType)
+}
+
+// PublishedFeedRSS handles reading a published feed as RSS 2.0, with the full
+// content of its articles in content:encoded.
+func PublishedFeedRSS(c *gin.Context) {
+	feed, _, articles, ok := loadPublishedFeed(c)
+	if !ok {
+		return
+	}
+
+	doc := rss.New(feed.Title, baseURL(c)+"/", feed.Title)
+	doc.Channel.SelfLink = &rss.SelfLink{Href: withFeedURLs(c, feed).RSSURL, Rel: atom.RelSelf, Type: rss.ContentType}
+	doc.Channel.LastBuildDate = rss.FormatTime(feedUpdated(feed, articles))
+	for _, article := range articles {
+		item := rss.Item{
+			Title:       article.Title,
+			Link:        article.URL,
+			GUID:        rss.GUID{Value: "urn:read-it-later:article:" + strconv.Itoa(article.ID)},
+			PubDate:     rss.FormatTime(article.CreatedAt),
+			Description: article.Excerpt,
+			Content:     article.ContentHTML,
+		}
+		if item.Content == "" && article.Content != "" {
+			item.Content = "<pre>" + html.EscapeString(article.Content) + "</pre>"
+		}
+		for _, tag := range article.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		log.Printf("Error writing published feed %d: %v", feed.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write feed"})
+		return
+	}
+	servePublishedFeed(c, buf.Bytes(), rss.ContentType)
+}
+
+// servePublishedFeed responds with a feed document, tagged with a hash of its
+// content so feed readers polling it get 304 Not Modified until it changes.
+func servePublishedFeed(c *gin.Context, body []byte, contentType string) {
+	sum := sha256.Sum256(body)
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(body))
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"read-it-later/backend/middleware"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// assetTokenLifetime is how long a token for asset URLs is valid. Clients
+	// request a new one before it expires.
+	assetTokenLifetime = time.Hour
+	// eventsTokenLifetime is how long a token for the event stream is valid.
+	// It is only checked when connecting, so clients request one for each
+	// connection.
+	eventsTokenLifetime = time.Minute
+)
+
+// issueScopedToken responds with a short-lived token of the signed-in user
+// that is only accepted in the token query parameter of the routes of scope.
+func issueScopedToken(c *gin.Context, scope string, lifetime time.Duration) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	expiresAt := time.Now().Add(lifetime)
+	claims := Claims{
+		UserID:   userID.(int),
+		Username: c.GetString("username"),
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokenString, "expires_at": expiresAt.UTC()})
+}
+
+// CreateAssetToken handles issuing a token for the URLs of article assets,
+// such as images in article content, which browsers request without the
+// Authorization header. It cannot be used for the rest of the API.
+func CreateAssetToken(c *gin.Context) {
+	issueScopedToken(c, middleware.ScopeAssets, assetTokenLifetime)
+}
+
+// CreateEventsToken handles issuing a token for connecting to the event
+// stream with EventSource, which cannot send the Authorization header. It
+// cannot be used for the rest of the API.
+func CreateEventsToken(c *gin.Context) {
+	issueScopedToken(c, middleware.ScopeEvents, eventsTokenLifetime)
+}
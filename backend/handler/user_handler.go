@@ -16,6 +16,7 @@ var jwtSecret = []byte("your-secret-key-change-this-in-production")
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetArticleWARC handles downloading the WARC archive recorded when an
+// article was last extracted, for replaying in web archive tools.
+func GetArticleWARC(c *gin.Context) {
+	serveArticleCapture(c, model.AssetWARC, "WARC archive", ".warc.gz", true, "default-src 'none'; sandbox")
+}
+
+// GetArticleWARCRecords handles listing the records of an article's WARC
+// archive with their offsets in the file.
+func GetArticleWARCRecords(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	if _, err := store.GetArticleByID(id, userID.(int)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve article"})
+		}
+		return
+	}
+
+	records, err := store.GetWARCRecords(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve WARC records"})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
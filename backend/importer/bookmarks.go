@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// parseNetscapeHTML reads a bookmark file in the Netscape format exported by
+// Chrome, Firefox, Safari and Edge. Bookmarks are nested in <DL> lists under
+// the <H3> headings of their folders; folders become tags as opts.FolderTags
+// says, except the browser's own root folders such as the bookmarks bar.
+// ADD_DATE becomes the saved date, and Firefox's TAGS attribute adds tags.
+func parseNetscapeHTML(data []byte, opts Options) ([]Item, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+
+	// pendingFolder is the folder named by the last heading, which applies to
+	// the list that follows it
+	var pendingFolder *string
+	var walk func(node *html.Node, folders []string)
+	walk = func(node *html.Node, folders []string) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "h3":
+				name := strings.TrimSpace(textContent(node))
+				if isRootFolder(node) {
+					name = ""
+				}
+				pendingFolder = &name
+				return
+			case "dl":
+				if pendingFolder != nil {
+					if *pendingFolder != "" {
+						folders = append(append([]string{}, folders...), *pendingFolder)
+					}
+					pendingFolder = nil
+				}
+			case "a":
+				item := Item{Title: strings.TrimSpace(textContent(node))}
+				for _, attr := range node.Attr {
+					switch attr.Key {
+					case "href":
+						item.URL = strings.TrimSpace(attr.Val)
+					case "add_date":
+						item.SavedAt = parseUnixTime(attr.Val)
+					case "tags":
+						item.Tags = append(item.Tags, splitTags(attr.Val, ",")...)
+					}
+				}
+				if item.URL == "" || strings.HasPrefix(item.URL, "place:") {
+					// Firefox writes smart folders as place: queries
+					return
+				}
+				item.Tags = append(folderTags(folders, opts.FolderTags), item.Tags...)
+				items = append(items, item)
+				return
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, folders)
+		}
+	}
+	walk(doc, nil)
+
+	return items, nil
+}
+
+// isRootFolder reports whether a folder heading is one of the folders that
+// browsers put every bookmark in, such as the bookmarks bar.
+func isRootFolder(heading *html.Node) bool {
+	for _, attr := range heading.Attr {
+		switch attr.Key {
+		case "personal_toolbar_folder", "unfiled_bookmarks_folder":
+			return strings.EqualFold(attr.Val, "true")
+		}
+	}
+	return false
+}
+
+// folderTags returns the tags for a bookmark in the given folders, outermost
+// first.
+func folderTags(folders []string, mode string) []string {
+	if len(folders) == 0 {
+		return nil
+	}
+	switch mode {
+	case FolderTagsNone:
+		return nil
+	case FolderTagsPath:
+		return []string{strings.Join(folders, "/")}
+	default:
+		return append([]string{}, folders...)
+	}
+}
@@ -0,0 +1,445 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"read-it-later/backend/model"
+
+	"golang.org/x/net/html"
+)
+
+// parsePocketHTML reads the HTML export of Pocket, which lists unread
+// articles under an "Unread" heading and finished ones under "Read Archive".
+func parsePocketHTML(data []byte) ([]Item, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	archived := false
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "h1", "h2":
+				archived = strings.Contains(strings.ToLower(textContent(node)), "archive")
+			case "a":
+				item := Item{
+					Title:      strings.TrimSpace(textContent(node)),
+					IsRead:     archived,
+					IsArchived: archived,
+				}
+				for _, attr := range node.Attr {
+					switch attr.Key {
+					case "href":
+						item.URL = strings.TrimSpace(attr.Val)
+					case "time_added":
+						item.SavedAt = parseUnixTime(attr.Val)
+					case "tags":
+						item.Tags = splitTags(attr.Val, ",")
+					}
+				}
+				if item.URL != "" {
+					items = append(items, item)
+				}
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	return items, nil
+}
+
+// textContent returns the text inside an HTML node.
+func textContent(node *html.Node) string {
+	var b strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			b.WriteString(node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+// csvRecords reads a CSV file with a header row and returns its rows as maps
+// from lower-case column names to values.
+func csvRecords(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]string, len(header))
+		for i, value := range row {
+			if i < len(header) {
+				record[header[i]] = value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parsePocketCSV reads the CSV export of Pocket, with the columns title, url,
+// time_added, tags (separated by "|") and status ("unread" or "archive").
+func parsePocketCSV(data []byte) ([]Item, error) {
+	records, err := csvRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(records))
+	for _, record := range records {
+		archived := strings.EqualFold(strings.TrimSpace(record["status"]), "archive")
+		items = append(items, Item{
+			URL:        strings.TrimSpace(record["url"]),
+			Title:      strings.TrimSpace(record["title"]),
+			Tags:       splitTags(record["tags"], "|"),
+			SavedAt:    parseUnixTime(record["time_added"]),
+			IsRead:     archived,
+			IsArchived: archived,
+		})
+	}
+	return items, nil
+}
+
+// parseInstapaperCSV reads the CSV export of Instapaper, with the columns
+// URL, Title, Selection, Folder and Timestamp, and in newer exports Tags as a
+// JSON list. The Unread, Archive and Starred folders set the reading state;
+// other folders become tags.
+func parseInstapaperCSV(data []byte) ([]Item, error) {
+	records, err := csvRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(records))
+	for _, record := range records {
+		item := Item{
+			URL:     strings.TrimSpace(record["url"]),
+			Title:   strings.TrimSpace(record["title"]),
+			SavedAt: parseUnixTime(record["timestamp"]),
+		}
+
+		switch folder := strings.TrimSpace(record["folder"]); strings.ToLower(folder) {
+		case "", "unread":
+		case "archive":
+			item.IsRead = true
+			item.IsArchived = true
+		case "starred":
+			item.IsFavorite = true
+		default:
+			item.Tags = append(item.Tags, folder)
+		}
+
+		if tags := strings.TrimSpace(record["tags"]); tags != "" {
+			var list []string
+			if err := json.Unmarshal([]byte(tags), &list); err == nil {
+				item.Tags = append(item.Tags, list...)
+			} else {
+				item.Tags = append(item.Tags, splitTags(tags, ",")...)
+			}
+		}
+
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// omnivoreItem is an article in the metadata files of an Omnivore export.
+type omnivoreItem struct {
+	URL             string            `json:"url"`
+	Title           string            `json:"title"`
+	State           string            `json:"state"`
+	ReadingProgress float64           `json:"readingProgress"`
+	Labels          []json.RawMessage `json:"labels"`
+	SavedAt         string            `json:"savedAt"`
+	ArchivedAt      string            `json:"archivedAt"`
+}
+
+// parseOmnivoreJSON reads an Omnivore export, either a metadata JSON file or
+// the zip file holding them. Labels become tags, archived articles are marked
+// as read and archived, and fully read articles as read.
+func parseOmnivoreJSON(data []byte) ([]Item, error) {
+	var files [][]byte
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range archive.File {
+			name := path.Base(file.Name)
+			if !strings.HasPrefix(name, "metadata") || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			content, err := readZipFile(file)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, content)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no metadata files in zip")
+		}
+	} else {
+		files = [][]byte{data}
+	}
+
+	var items []Item
+	for _, content := range files {
+		var entries []omnivoreItem
+		if err := json.Unmarshal(content, &entries); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			archived := strings.EqualFold(entry.State, "archived") || entry.ArchivedAt != ""
+			item := Item{
+				URL:        strings.TrimSpace(entry.URL),
+				Title:      strings.TrimSpace(entry.Title),
+				SavedAt:    parseTime(entry.SavedAt),
+				IsRead:     archived || entry.ReadingProgress >= 100,
+				IsArchived: archived,
+			}
+			for _, label := range entry.Labels {
+				if name := labelName(label); name != "" {
+					item.Tags = append(item.Tags, name)
+				}
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// maxZipFileSize limits the size of a file read from an uploaded zip.
+const maxZipFileSize = 100 << 20
+
+// readZipFile reads a file from a zip archive.
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxZipFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxZipFileSize {
+		return nil, fmt.Errorf("%s larger than %d bytes", file.Name, maxZipFileSize)
+	}
+	return data, nil
+}
+
+// labelName reads an Omnivore label, which exports give as a string or as
+// an object with a name.
+func labelName(label json.RawMessage) string {
+	var name string
+	if err := json.Unmarshal(label, &name); err == nil {
+		return strings.TrimSpace(name)
+	}
+	var object struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(label, &object); err == nil {
+		return strings.TrimSpace(object.Name)
+	}
+	return ""
+}
+
+// wallabagFlag is a boolean that Wallabag exports write as 0/1 or true/false.
+type wallabagFlag bool
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *wallabagFlag) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	*f = wallabagFlag(value == "1" || value == "true")
+	return nil
+}
+
+// wallabagEntry is an article in a Wallabag JSON export.
+type wallabagEntry struct {
+	URL        string       `json:"url"`
+	Title      string       `json:"title"`
+	IsArchived wallabagFlag `json:"is_archived"`
+	IsStarred  wallabagFlag `json:"is_starred"`
+	Tags       []string     `json:"tags"`
+	CreatedAt  string       `json:"created_at"`
+}
+
+// parseWallabagJSON reads a Wallabag JSON export. Archived entries are marked
+// as read and archived, and starred entries as favorites.
+func parseWallabagJSON(data []byte) ([]Item, error) {
+	var entries []wallabagEntry
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &entries); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, Item{
+			URL:        strings.TrimSpace(entry.URL),
+			Title:      strings.TrimSpace(entry.Title),
+			Tags:       entry.Tags,
+			SavedAt:    parseTime(entry.CreatedAt),
+			IsRead:     bool(entry.IsArchived),
+			IsArchived: bool(entry.IsArchived),
+			IsFavorite: bool(entry.IsStarred),
+		})
+	}
+	return items, nil
+}
+
+// parseReadItLater reads a JSON export of Read It Later, keeping the whole
+// article of each item so it can be restored as it was exported. Articles are
+// decoded and passed to add one at a time, so large backups are never held in
+// memory.
+func parseReadItLater(r io.Reader, add func(Item) error) error {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	dec := json.NewDecoder(br)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var export model.LibraryExport
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "format":
+			err = dec.Decode(&export.Format)
+		case "version":
+			err = dec.Decode(&export.Version)
+		case "articles":
+			err = decodeExportedArticles(dec, add)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if export.Format != model.ExportFormat {
+		return fmt.Errorf("not a Read It Later export")
+	}
+	if export.Version < 1 || export.Version > model.ExportVersion {
+		return fmt.Errorf("unsupported export version %d", export.Version)
+	}
+	return nil
+}
+
+// decodeExportedArticles decodes the articles array of a Read It Later export
+// and passes each article to add.
+func decodeExportedArticles(dec *json.Decoder, add func(Item) error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		article := &model.ExportedArticle{}
+		if err := dec.Decode(article); err != nil {
+			return err
+		}
+		article.URL = strings.TrimSpace(article.URL)
+		err := add(Item{
+			URL:        article.URL,
+			Title:      article.Title,
+			Tags:       article.Tags,
+			SavedAt:    article.CreatedAt,
+			IsRead:     article.IsRead,
+			IsArchived: article.IsArchived,
+			IsFavorite: article.IsFavorite,
+			Exported:   article,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next JSON token, which must be the delimiter delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s in JSON", delim)
+	}
+	return nil
+}
+
+// timeLayouts are the layouts of dates in JSON exports.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+}
+
+// parseTime reads a date from a JSON export, or a Unix timestamp in seconds
+// or milliseconds. It returns the zero time if the date cannot be read.
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+		if n > 1e12 {
+			return time.UnixMilli(n).UTC()
+		}
+		return time.Unix(n, 0).UTC()
+	}
+	return time.Time{}
+}
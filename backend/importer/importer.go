@@ -0,0 +1,229 @@
+// Package importer reads the exports of other read-later services and saves
+// their articles, with tags, reading state and saved dates, queueing each one
+// for extraction. Exports of Read It Later itself are restored with their
+// content, highlights and reading progress. Imports run in the background and
+// record the outcome of every item so clients can report it.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"read-it-later/backend/model"
+)
+
+// Export formats.
+const (
+	FormatPocketHTML    = "pocket_html"
+	FormatPocketCSV     = "pocket_csv"
+	FormatInstapaperCSV = "instapaper_csv"
+	FormatOmnivoreJSON  = "omnivore_json"
+	FormatWallabagJSON  = "wallabag_json"
+	FormatNetscapeHTML  = "netscape_html"
+	FormatReadItLater   = "read_it_later_json"
+)
+
+// Ways bookmark folders become tags.
+const (
+	// FolderTagsEach tags a bookmark with each folder it is in.
+	FolderTagsEach = "each"
+	// FolderTagsPath tags a bookmark with the path of its folder, such as
+	// "Tech/Go".
+	FolderTagsPath = "path"
+	// FolderTagsNone ignores folders.
+	FolderTagsNone = "none"
+)
+
+// Options adjusts how exports are read.
+type Options struct {
+	// FolderTags is how bookmark folders become tags, FolderTagsEach by
+	// default.
+	FolderTags string
+}
+
+// Size limits of exports. Read It Later backups hold the content of every
+// article, so they may be much larger than the exports of other services.
+const (
+	MaxExportSize = 50 << 20
+	MaxBackupSize = 1 << 30
+)
+
+// detectSize is the length of the start of an export read by Detect.
+const detectSize = 4096
+
+// ErrUnknownFormat is returned for files whose format cannot be recognized.
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// ErrTooLarge is returned for exports of other services larger than
+// MaxExportSize.
+var ErrTooLarge = errors.New("export too large")
+
+// ErrNoArticles is returned for exports without any article.
+var ErrNoArticles = errors.New("no articles in export")
+
+// ReadError is returned by Start for exports that cannot be read.
+type ReadError struct {
+	Format string
+	Err    error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("reading %s export: %v", e.Format, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Item is an article read from an export.
+type Item struct {
+	URL   string
+	Title string
+	Tags  []string
+	// SavedAt is when the article was saved to the other service, or zero
+	// if the export does not say.
+	SavedAt    time.Time
+	IsRead     bool
+	IsArchived bool
+	IsFavorite bool
+	// Exported is the whole article for Read It Later exports.
+	Exported *model.ExportedArticle
+}
+
+// parsers maps formats to the functions reading them. Read It Later backups
+// are decoded as they are read by parseReadItLater instead.
+var parsers = map[string]func(data []byte, opts Options) ([]Item, error){
+	FormatPocketHTML:    ignoreOptions(parsePocketHTML),
+	FormatPocketCSV:     ignoreOptions(parsePocketCSV),
+	FormatInstapaperCSV: ignoreOptions(parseInstapaperCSV),
+	FormatOmnivoreJSON:  ignoreOptions(parseOmnivoreJSON),
+	FormatWallabagJSON:  ignoreOptions(parseWallabagJSON),
+	FormatNetscapeHTML:  parseNetscapeHTML,
+}
+
+// ignoreOptions adapts a parser that has no options.
+func ignoreOptions(parse func(data []byte) ([]Item, error)) func(data []byte, opts Options) ([]Item, error) {
+	return func(data []byte, opts Options) ([]Item, error) {
+		return parse(data)
+	}
+}
+
+// IsValidFormat reports whether format names a supported export format.
+func IsValidFormat(format string) bool {
+	_, ok := parsers[format]
+	return ok || format == FormatReadItLater
+}
+
+// detectFormat returns the format of an export, detected from its start if
+// format is empty, and a reader of the whole export.
+func detectFormat(format string, r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, detectSize)
+	if format == "" {
+		// A short export fails to fill the buffer; the error is returned by
+		// the reads that follow if it is not that
+		head, _ := br.Peek(detectSize)
+		format = Detect(head)
+	}
+	if !IsValidFormat(format) {
+		return "", nil, ErrUnknownFormat
+	}
+	return format, br, nil
+}
+
+// readItems reads the items of an export and passes them to add in file
+// order. Read It Later backups are decoded one article at a time; exports of
+// other services are read whole, return ErrTooLarge past MaxExportSize and
+// have items with the same URL merged. Errors returned by add stop reading
+// and are returned as they are.
+func readItems(format string, r io.Reader, opts Options, add func(Item) error) error {
+	if format == FormatReadItLater {
+		return parseReadItLater(r, add)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxExportSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxExportSize {
+		return ErrTooLarge
+	}
+	items, err := parsers[format](data, opts)
+	if err != nil {
+		return err
+	}
+	for _, item := range mergeDuplicates(items) {
+		if err := add(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Detect guesses the format of an export from its content. It returns an
+// empty string if the format is not recognized.
+func Detect(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	head := strings.ToLower(string(data[:min(len(data), detectSize)]))
+	trimmed := strings.TrimSpace(head)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		// Omnivore exports come as a zip of JSON files
+		return FormatOmnivoreJSON
+	case strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{"):
+		if strings.Contains(head, `"`+model.ExportFormat+`"`) {
+			return FormatReadItLater
+		}
+		if strings.Contains(head, `"is_archived"`) || strings.Contains(head, `"is_starred"`) {
+			return FormatWallabagJSON
+		}
+		if strings.Contains(head, `"savedat"`) || strings.Contains(head, `"labels"`) || strings.Contains(head, `"slug"`) {
+			return FormatOmnivoreJSON
+		}
+	case strings.Contains(head, "netscape-bookmark-file"):
+		return FormatNetscapeHTML
+	case strings.Contains(head, "<!doctype") || strings.Contains(head, "<html") || strings.Contains(head, "<ul"):
+		if strings.Contains(head, "pocket") || strings.Contains(head, "time_added") {
+			return FormatPocketHTML
+		}
+	default:
+		header := strings.SplitN(trimmed, "\n", 2)[0]
+		if strings.HasPrefix(header, "url,title,selection,folder") {
+			return FormatInstapaperCSV
+		}
+		if strings.Contains(header, "time_added") && strings.Contains(header, "url") {
+			return FormatPocketCSV
+		}
+	}
+	return ""
+}
+
+// parseUnixTime reads a timestamp given in seconds since the epoch. Some
+// browsers write bookmark dates in microseconds, which are recognized too.
+func parseUnixTime(value string) time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	if seconds > 1e14 {
+		return time.UnixMicro(seconds).UTC()
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// splitTags splits a list of tags on sep, dropping empty ones.
+func splitTags(value string, sep string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"read-it-later/backend/model"
+	"read-it-later/backend/queue"
+	"read-it-later/backend/store"
+)
+
+const (
+	// batchSize is the number of items imported between queue notifications.
+	batchSize = 50
+	// maxTitleLength limits the length of imported titles.
+	maxTitleLength = 500
+)
+
+// Resume continues the imports interrupted by a previous shutdown. Items are
+// saved with the import, so only the ones not imported yet are processed.
+func Resume() {
+	if n, err := store.DeleteReadingImports(); err != nil {
+		log.Printf("Error deleting unread imports: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d imports whose export was still being read", n)
+	}
+
+	imports, err := store.GetRunningImports()
+	if err != nil {
+		log.Printf("Error loading interrupted imports: %v", err)
+		return
+	}
+	for _, imp := range imports {
+		log.Printf("Resuming import %d with %d pending items", imp.ID, imp.Pending)
+		go run(imp)
+	}
+}
+
+// Start reads an export in the given format, detected from its content if
+// empty, saves its items with a new import as they are read and starts
+// importing them in the background. Items with the same URL are imported
+// once. Exports that cannot be read return a *ReadError, ErrUnknownFormat or
+// ErrNoArticles, and leave no import behind.
+func Start(userID int, format string, filename string, r io.Reader, opts Options) (model.Import, error) {
+	format, r, err := detectFormat(format, r)
+	if err != nil {
+		return model.Import{}, err
+	}
+
+	imp, err := store.CreateImport(model.Import{UserID: userID, Format: format, Filename: filename})
+	if err != nil {
+		return model.Import{}, err
+	}
+
+	count, err := saveItems(imp.ID, format, r, opts)
+	if err == nil && count == 0 {
+		err = ErrNoArticles
+	}
+	if err == nil {
+		err = store.StartImport(imp.ID)
+	}
+	if err != nil {
+		if err := store.DeleteImport(imp.ID); err != nil {
+			log.Printf("Error deleting import %d: %v", imp.ID, err)
+		}
+		return model.Import{}, err
+	}
+
+	imp, err = store.GetImportByID(imp.ID, userID)
+	if err != nil {
+		return model.Import{}, err
+	}
+
+	go run(imp)
+	return imp, nil
+}
+
+// saveItems reads the items of an export and saves them to an import in
+// batches, so large backups are never held in memory whole. It returns the
+// number of items saved.
+func saveItems(importID int, format string, r io.Reader, opts Options) (int, error) {
+	var batch []model.ImportItem
+	var saveErr error
+	count := 0
+	save := func() error {
+		saveErr = store.AddImportItems(importID, batch)
+		count += len(batch)
+		batch = batch[:0]
+		return saveErr
+	}
+
+	err := readItems(format, r, opts, func(item Item) error {
+		batch = append(batch, pendingItem(item))
+		if len(batch) < batchSize {
+			return nil
+		}
+		return save()
+	})
+	if saveErr != nil {
+		return 0, saveErr
+	}
+	if err != nil {
+		return 0, &ReadError{Format: format, Err: err}
+	}
+	if err := save(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// pendingItem converts an item read from an export to an import item.
+func pendingItem(item Item) model.ImportItem {
+	pending := model.ImportItem{
+		URL:        item.URL,
+		Title:      truncate(strings.TrimSpace(item.Title), maxTitleLength),
+		Tags:       cleanTags(item.Tags),
+		IsRead:     item.IsRead,
+		IsArchived: item.IsArchived,
+		IsFavorite: item.IsFavorite,
+		Exported:   item.Exported,
+	}
+	if pending.Tags == nil {
+		pending.Tags = []string{}
+	}
+	if !item.SavedAt.IsZero() {
+		savedAt := item.SavedAt
+		pending.SavedAt = &savedAt
+	}
+	return pending
+}
+
+// run imports the pending items of an import in batches.
+func run(imp model.Import) {
+	for {
+		items, err := store.GetPendingImportItems(imp.ID, batchSize)
+		if err != nil {
+			log.Printf("Error loading items of import %d: %v", imp.ID, err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			status, articleID, errMsg := importItem(imp.UserID, item)
+			if err := store.CompleteImportItem(item.ID, status, articleID, errMsg); err != nil {
+				log.Printf("Error recording item %d of import %d: %v", item.ID, imp.ID, err)
+				return
+			}
+		}
+
+		// Let the workers start extracting while the rest is imported
+		queue.Notify()
+	}
+
+	if err := store.FinishImport(imp.ID); err != nil {
+		log.Printf("Error finishing import %d: %v", imp.ID, err)
+		return
+	}
+	log.Printf("Finished import %d", imp.ID)
+}
+
+// importItem saves an item as an article queued for extraction. It returns
+// the status of the item, the article saved or already saved with its URL,
+// and an error message for failed items.
+func importItem(userID int, item model.ImportItem) (string, int, string) {
+	parsedURL, err := url.ParseRequestURI(item.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return model.ImportItemFailed, 0, "Invalid URL"
+	}
+
+	if existingID, err := store.GetArticleIDByURL(userID, item.URL); err == nil {
+		return model.ImportItemSkipped, existingID, "Article already saved"
+	} else if err != sql.ErrNoRows {
+		log.Printf("Error checking %s: %v", item.URL, err)
+		return model.ImportItemFailed, 0, "Failed to save article"
+	}
+
+	title := item.Title
+	if title == "" {
+		title = item.URL
+	}
+	article := model.Article{
+		UserID:     userID,
+		URL:        item.URL,
+		Title:      title,
+		IsRead:     item.IsRead,
+		IsArchived: item.IsArchived,
+		IsFavorite: item.IsFavorite,
+	}
+	if item.SavedAt != nil {
+		article.CreatedAt = *item.SavedAt
+	}
+
+	var articleID int
+	if item.Exported != nil {
+		// Articles that were extracted are restored without extracting them
+		// again, so only the others take a slot
+		var runAt time.Time
+		if item.Exported.Status != model.ArticleReady {
+			runAt = extractionSlot(item.URL)
+		}
+		articleID, err = store.RestoreArticle(userID, *item.Exported, item.Tags, runAt)
+	} else {
+		articleID, err = store.ImportArticle(article, item.Tags, extractionSlot(item.URL))
+	}
+	if errors.Is(err, store.ErrArticleExists) {
+		return model.ImportItemSkipped, articleID, "Article already saved"
+	}
+	if err != nil {
+		log.Printf("Error importing %s: %v", item.URL, err)
+		return model.ImportItemFailed, 0, "Failed to save article"
+	}
+	return model.ImportItemImported, articleID, ""
+}
+
+// mergeDuplicates merges items with the same URL into the first of them,
+// keeping the tags of all of them and the earliest saved date.
+func mergeDuplicates(items []Item) []Item {
+	seen := make(map[string]int)
+	merged := make([]Item, 0, len(items))
+	for _, item := range items {
+		i, ok := seen[item.URL]
+		if !ok {
+			seen[item.URL] = len(merged)
+			merged = append(merged, item)
+			continue
+		}
+
+		first := &merged[i]
+		first.Tags = append(first.Tags, item.Tags...)
+		if !item.SavedAt.IsZero() && (first.SavedAt.IsZero() || item.SavedAt.Before(first.SavedAt)) {
+			first.SavedAt = item.SavedAt
+		}
+		if first.Title == "" {
+			first.Title = item.Title
+		}
+	}
+	return merged
+}
+
+// cleanTags trims tags and drops empty and repeated ones.
+func cleanTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var cleaned []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostInterval is the least time between extractions of imported articles
+// from the same site, so that large imports do not hammer it.
+const hostInterval = 10 * time.Second
+
+// throttle spreads the extraction of imported articles over time. Every
+// article gets a slot extractionInterval after the previous one and
+// hostInterval after the previous one from the same site, across all running
+// imports. Articles saved by users directly are not throttled.
+var throttle = struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+	hosts    map[string]time.Time
+}{
+	interval: 2 * time.Second,
+	hosts:    make(map[string]time.Time),
+}
+
+// SetExtractionInterval sets the least time between extractions of imported
+// articles, such as "2s". Zero extracts them as fast as the workers can.
+func SetExtractionInterval(value string) error {
+	interval, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	if interval < 0 {
+		return fmt.Errorf("negative interval %s", interval)
+	}
+
+	throttle.Lock()
+	defer throttle.Unlock()
+	throttle.interval = interval
+	return nil
+}
+
+// extractionSlot reserves the time at which an imported article from the
+// given URL may be extracted. It returns the zero time when extraction is not
+// throttled or may start right away.
+func extractionSlot(rawURL string) time.Time {
+	throttle.Lock()
+	defer throttle.Unlock()
+
+	if throttle.interval == 0 {
+		return time.Time{}
+	}
+
+	now := time.Now()
+	slot := now
+	if throttle.next.After(slot) {
+		slot = throttle.next
+	}
+
+	host := ""
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	}
+	if next, ok := throttle.hosts[host]; ok && next.After(slot) {
+		slot = next
+	}
+
+	throttle.next = slot.Add(throttle.interval)
+	throttle.hosts[host] = slot.Add(hostInterval)
+
+	// Forget sites whose slots have passed
+	if len(throttle.hosts) > 1000 {
+		for h, next := range throttle.hosts {
+			if next.Before(now) {
+				delete(throttle.hosts, h)
+			}
+		}
+	}
+
+	if !slot.After(now) {
+		return time.Time{}
+	}
+	return slot
+}
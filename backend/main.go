@@ -4,9 +4,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"read-it-later/backend/assets"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/feeds"
 	"read-it-later/backend/handler"
+	"read-it-later/backend/importer"
 	"read-it-later/backend/middleware"
+	"read-it-later/backend/queue"
 	"read-it-later/backend/store"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -29,13 +36,77 @@ func main() {
 	store.InitDB(dbFileName)
 	log.Println("Database initialized successfully at:", dbFileName)
 
+	// 阅读进度超过该百分比时自动标记为已读
+	if threshold := os.Getenv("READ_PROGRESS_THRESHOLD"); threshold != "" {
+		value, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || value < 0 || value > 100 {
+			log.Fatalf("Invalid READ_PROGRESS_THRESHOLD: %q", threshold)
+		}
+		handler.SetReadThreshold(value)
+	}
+
+	// 站点提取规则目录（ftr-site-config 格式）
+	siteRulesDir := os.Getenv("SITE_RULES_DIR")
+	if siteRulesDir == "" {
+		siteRulesDir = filepath.Join(dataDir, "site-rules")
+	}
+	extractor.SetSiteConfigDir(siteRulesDir)
+
+	// 文章图片等本地资源按内容哈希存放在数据目录下
+	assets.SetDir(filepath.Join(dataDir, "assets"))
+
+	// 提取完成后用无头浏览器保存的页面形式（snapshot、pdf、screenshot，逗号分隔，或 none 关闭）
+	if value, ok := os.LookupEnv("CAPTURE_MODES"); ok {
+		if err := queue.SetCaptureModes(strings.Split(value, ",")); err != nil {
+			log.Fatalf("Invalid CAPTURE_MODES: %v", err)
+		}
+	}
+
+	// 提取文章时记录的 WARC 归档（http 记录抓取的页面，browser 同时记录无头浏览器的网络请求，none 关闭）
+	if value, ok := os.LookupEnv("WARC_RECORDING"); ok {
+		if err := queue.SetWARCRecording(value); err != nil {
+			log.Fatalf("Invalid WARC_RECORDING: %v", err)
+		}
+	}
+
+	// 启动后台内容提取任务的工作池
+	workers := 2
+	if value := os.Getenv("EXTRACTION_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid EXTRACTION_WORKERS: %q", value)
+		}
+		workers = n
+	}
+	queue.Start(workers)
+	log.Printf("Started %d extraction workers", workers)
+
+	// 导入文章的提取间隔，避免大批量导入时集中抓取
+	if value, ok := os.LookupEnv("IMPORT_EXTRACTION_INTERVAL"); ok {
+		if err := importer.SetExtractionInterval(value); err != nil {
+			log.Fatalf("Invalid IMPORT_EXTRACTION_INTERVAL: %v", err)
+		}
+	}
+
+	// 继续上次关闭时未完成的导入
+	importer.Resume()
+
+	// 定时拉取订阅源，新文章自动保存
+	if value, ok := os.LookupEnv("FEED_POLL_INTERVAL"); ok {
+		if err := feeds.SetPollInterval(value); err != nil {
+			log.Fatalf("Invalid FEED_POLL_INTERVAL: %v", err)
+		}
+	}
+	feeds.Start()
+
 	// Set up the Gin router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
 
 	// 添加 CORS 中间件
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
@@ -61,6 +132,11 @@ func main() {
 		user.Use(middleware.AuthMiddleware())
 		{
 			user.GET("/profile", handler.GetProfile)
+			user.GET("/catalog-token", handler.GetCatalogToken)
+			user.POST("/catalog-token", handler.RotateCatalogToken)
+			user.DELETE("/catalog-token", handler.RevokeCatalogToken)
+			user.POST("/asset-token", handler.CreateAssetToken)
+			user.POST("/events-token", handler.CreateEventsToken)
 		}
 
 		// 需要认证的文章相关路由
@@ -71,11 +147,96 @@ func main() {
 			articles.GET("/search", handler.SearchArticles)
 			articles.POST("", handler.AddArticle)
 			articles.GET("/:id", handler.GetArticle)
+			articles.PATCH("/:id", handler.UpdateArticle)
+			articles.GET("/:id/progress", handler.GetReadingProgress)
+			articles.PUT("/:id/progress", handler.UpdateReadingProgress)
+			articles.GET("/:id/highlights", handler.GetHighlights)
+			articles.POST("/:id/highlights", handler.AddHighlight)
+			articles.PUT("/:id/highlights/:highlightId", handler.UpdateHighlight)
+			articles.DELETE("/:id/highlights/:highlightId", handler.DeleteHighlight)
+			articles.POST("/:id/refresh", handler.RefreshArticle)
+			articles.GET("/:id/warc/records", handler.GetArticleWARCRecords)
 			articles.POST("/:id/tags", handler.AddTagToArticle)
 			articles.DELETE("/:id/tags/:tagId", handler.RemoveTagFromArticle)
 			articles.DELETE("/:id", handler.DeleteArticle)
 		}
 
+		// 导出文章（需要认证）
+		export := api.Group("/export")
+		export.Use(middleware.AuthMiddleware())
+		{
+			export.GET("", handler.ExportLibrary)
+			export.GET("/epub", handler.ExportEPUB)
+		}
+
+		// OPDS 目录，供电子书阅读器使用（支持 HTTP Basic 认证或目录令牌查询参数）
+		opds := api.Group("/opds")
+		opds.Use(middleware.CatalogAuthMiddleware())
+		{
+			opds.GET("", handler.OPDSCatalog)
+			opds.GET("/unread", handler.OPDSUnread)
+			opds.GET("/tags", handler.OPDSTags)
+			opds.GET("/tags/:id", handler.OPDSTag)
+			opds.GET("/articles/:id/epub", handler.OPDSArticleEPUB)
+			opds.GET("/articles/:id/html", handler.OPDSArticleHTML)
+		}
+
+		// 从其他稍后读服务导入文章（需要认证）
+		imports := api.Group("/imports")
+		imports.Use(middleware.AuthMiddleware())
+		{
+			imports.POST("", handler.CreateImport)
+			imports.GET("", handler.GetImports)
+			imports.GET("/:id", handler.GetImport)
+			imports.GET("/:id/items", handler.GetImportItems)
+		}
+
+		// 订阅源相关路由（需要认证）
+		feedRoutes := api.Group("/feeds")
+		feedRoutes.Use(middleware.AuthMiddleware())
+		{
+			feedRoutes.POST("", handler.CreateFeed)
+			feedRoutes.GET("", handler.GetFeeds)
+			feedRoutes.GET("/:id", handler.GetFeed)
+			feedRoutes.PATCH("/:id", handler.UpdateFeed)
+			feedRoutes.DELETE("/:id", handler.DeleteFeed)
+			feedRoutes.POST("/:id/refresh", handler.RefreshFeed)
+			feedRoutes.GET("/:id/entries", handler.GetFeedEntries)
+		}
+
+		// 发布文章订阅源（需要认证）
+		publishedFeeds := api.Group("/published-feeds")
+		publishedFeeds.Use(middleware.AuthMiddleware())
+		{
+			publishedFeeds.POST("", handler.CreatePublishedFeed)
+			publishedFeeds.GET("", handler.GetPublishedFeeds)
+			publishedFeeds.POST("/:id/rotate", handler.RotatePublishedFeedToken)
+			publishedFeeds.DELETE("/:id", handler.DeletePublishedFeed)
+		}
+
+		// 发布的订阅源，供 RSS 阅读器使用（通过 URL 中的令牌访问）
+		api.GET("/published/:token/atom", handler.PublishedFeedAtom)
+		api.GET("/published/:token/rss", handler.PublishedFeedRSS)
+
+		// 后台任务状态查询（需要认证）
+		jobs := api.Group("/jobs")
+		jobs.Use(middleware.AuthMiddleware())
+		{
+			jobs.GET("/:id", handler.GetJob)
+		}
+
+		// 实时事件推送（SSE，支持通过 token 查询参数传递事件令牌）
+		api.GET("/events", middleware.StreamAuthMiddleware(middleware.ScopeEvents), handler.StreamEvents)
+
+		// 文章的本地图片等资源（<img> 无法设置请求头，支持通过 token 查询参数传递资源令牌）
+		api.GET("/assets/:hash", middleware.StreamAuthMiddleware(middleware.ScopeAssets), handler.GetAsset)
+
+		// 文章的页面快照、PDF 和截图（可在浏览器中直接打开，支持通过 token 查询参数传递资源令牌）
+		api.GET("/articles/:id/snapshot", middleware.StreamAuthMiddleware(middleware.ScopeAssets), handler.GetArticleSnapshot)
+		api.GET("/articles/:id/pdf", middleware.StreamAuthMiddleware(middleware.ScopeAssets), handler.GetArticlePDF)
+		api.GET("/articles/:id/screenshot", middleware.StreamAuthMiddleware(middleware.ScopeAssets), handler.GetArticleScreenshot)
+		api.GET("/articles/:id/warc", middleware.StreamAuthMiddleware(middleware.ScopeAssets), handler.GetArticleWARC)
+
 		// Image proxy to handle anti-hotlinking (公开访问)
 		api.GET("/proxy/image", handler.ProxyImage)
 	}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"net/http"
+	"read-it-later/backend/store"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -10,9 +12,22 @@ import (
 
 var jwtSecret = []byte("your-secret-key-change-this-in-production")
 
+// basicAuthChallenge 提示客户端使用 HTTP Basic 认证
+const basicAuthChallenge = `Basic realm="Read It Later", charset="UTF-8"`
+
+// 受限令牌的用途。这类令牌放在 URL 中传递，只能用于对应的路由，
+// 登录 JWT 的 Scope 为空
+const (
+	// ScopeAssets 令牌只能获取文章的本地图片、快照、PDF、截图和 WARC 归档
+	ScopeAssets = "assets"
+	// ScopeEvents 令牌只能订阅实时事件
+	ScopeEvents = "events"
+)
+
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -34,32 +49,115 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 解析token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		authenticate(c, tokenString, "")
+	}
+}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+// StreamAuthMiddleware 验证JWT token，并允许通过 token 查询参数传递，
+// 因为 <img> 和浏览器的 EventSource 无法设置请求头。查询参数中只接受
+// 用途为 scope 的短期令牌，以免登录 JWT 出现在 URL 和日志中
+func StreamAuthMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); tokenString != "" {
+			authenticate(c, tokenString, "")
 			return
 		}
 
-		if claims, ok := token.Claims.(*Claims); ok {
-			// 将用户信息存储在上下文中
-			c.Set("user_id", claims.UserID)
-			c.Set("username", claims.Username)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
 			c.Abort()
 			return
 		}
 
-		c.Next()
+		authenticate(c, tokenString, scope)
 	}
 }
 
+// authenticate 解析token并将用户信息存储在上下文中，token 的用途必须为 scope
+func authenticate(c *gin.Context, tokenString string, scope string) {
+	// 解析token
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	if claims, ok := token.Claims.(*Claims); ok && claims.Scope == scope {
+		// 将用户信息存储在上下文中
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
 // GetJWTSecret 获取JWT密钥
 func GetJWTSecret() []byte {
 	return jwtSecret
 }
+
+// CatalogAuthMiddleware 为电子书阅读器（OPDS 客户端）提供认证：
+// 支持 HTTP Basic 认证、Bearer 头传递 JWT，或通过 token 查询参数传递
+// 长期有效的目录令牌（登录 JWT 一天后过期，不适合配置在阅读器中）。
+// 认证失败时返回 WWW-Authenticate 头，以便客户端提示输入用户名和密码。
+// Basic 认证的凭据验证通过后缓存几分钟，失败次数过多时暂时拒绝（429）
+func CatalogAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if username, password, ok := c.Request.BasicAuth(); ok {
+			userID, name, err := verifyBasicAuth(c.ClientIP(), username, password)
+			if err != nil {
+				switch err {
+				case errInvalidCredentials:
+					c.Header("WWW-Authenticate", basicAuthChallenge)
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				case errTooManyFailures:
+					c.Header("Retry-After", strconv.Itoa(int(basicAuthFailureWindow.Seconds())))
+					c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, please try again later"})
+				default:
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify credentials"})
+				}
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", userID)
+			c.Set("username", name)
+			c.Next()
+			return
+		}
+
+		if token := c.Query("token"); token != "" {
+			user, err := store.GetUserByCatalogToken(token)
+			if err != nil {
+				c.Header("WWW-Authenticate", basicAuthChallenge)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid catalog token"})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", user.ID)
+			c.Set("username", user.Username)
+			c.Next()
+			return
+		}
+
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			c.Header("WWW-Authenticate", basicAuthChallenge)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		authenticate(c, tokenString, "")
+	}
+}
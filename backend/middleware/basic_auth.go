@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"read-it-later/backend/store"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// basicAuthCacheTTL 验证通过的 Basic 认证凭据的缓存时间，阅读器翻页和加载
+	// 图片时不必每次都比较 bcrypt 哈希
+	basicAuthCacheTTL = 5 * time.Minute
+	// maxBasicAuthFailures 在 basicAuthFailureWindow 内，每个客户端 IP 和每个
+	// 用户名允许的认证失败次数
+	maxBasicAuthFailures   = 10
+	basicAuthFailureWindow = 15 * time.Minute
+	// basicAuthPruneSize 记录超过此数量时清理已过期的记录
+	basicAuthPruneSize = 1000
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errTooManyFailures    = errors.New("too many failed attempts")
+)
+
+// verifiedCredentials 缓存的已验证凭据
+type verifiedCredentials struct {
+	userID   int
+	username string
+	expires  time.Time
+}
+
+// authFailures 一个时间窗口内的认证失败次数
+type authFailures struct {
+	count int
+	reset time.Time
+}
+
+// basicAuth 保存已验证凭据的缓存，以及按客户端 IP（键以 "ip:" 开头）和
+// 用户名（键以 "user:" 开头）统计的认证失败次数
+var basicAuth = struct {
+	sync.Mutex
+	verified map[[sha256.Size]byte]verifiedCredentials
+	failures map[string]*authFailures
+}{
+	verified: make(map[[sha256.Size]byte]verifiedCredentials),
+	failures: make(map[string]*authFailures),
+}
+
+// verifyBasicAuth 验证 Basic 认证的用户名和密码，返回用户 ID 和用户名。
+// 最近验证过的凭据直接从缓存返回；客户端 IP 或用户名失败次数过多时返回
+// errTooManyFailures，不再比较密码
+func verifyBasicAuth(ip string, username string, password string) (int, string, error) {
+	// 缓存只在内存中，以用户名和密码的哈希为键，不保存密码本身
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+	ipKey, userKey := "ip:"+ip, "user:"+username
+	now := time.Now()
+
+	basicAuth.Lock()
+	if cached, ok := basicAuth.verified[key]; ok && now.Before(cached.expires) {
+		basicAuth.Unlock()
+		return cached.userID, cached.username, nil
+	}
+	if tooManyFailures(ipKey, now) || tooManyFailures(userKey, now) {
+		basicAuth.Unlock()
+		return 0, "", errTooManyFailures
+	}
+	basicAuth.Unlock()
+
+	user, err := store.GetUserByUsername(username)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, "", err
+	}
+	if err == sql.ErrNoRows || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		basicAuth.Lock()
+		recordFailure(ipKey, now)
+		recordFailure(userKey, now)
+		basicAuth.Unlock()
+		return 0, "", errInvalidCredentials
+	}
+
+	basicAuth.Lock()
+	defer basicAuth.Unlock()
+	if len(basicAuth.verified) >= basicAuthPruneSize {
+		for k, cached := range basicAuth.verified {
+			if !now.Before(cached.expires) {
+				delete(basicAuth.verified, k)
+			}
+		}
+	}
+	basicAuth.verified[key] = verifiedCredentials{userID: user.ID, username: user.Username, expires: now.Add(basicAuthCacheTTL)}
+	delete(basicAuth.failures, userKey)
+	return user.ID, user.Username, nil
+}
+
+// tooManyFailures 判断 key 在当前时间窗口内的失败次数是否已达上限，
+// 调用时须持有 basicAuth 的锁
+func tooManyFailures(key string, now time.Time) bool {
+	failures, ok := basicAuth.failures[key]
+	return ok && now.Before(failures.reset) && failures.count >= maxBasicAuthFailures
+}
+
+// recordFailure 记录 key 的一次认证失败，调用时须持有 basicAuth 的锁
+func recordFailure(key string, now time.Time) {
+	if len(basicAuth.failures) >= basicAuthPruneSize {
+		for k, failures := range basicAuth.failures {
+			if !now.Before(failures.reset) {
+				delete(basicAuth.failures, k)
+			}
+		}
+	}
+
+	failures, ok := basicAuth.failures[key]
+	if !ok || !now.Before(failures.reset) {
+		failures = &authFailures{reset: now.Add(basicAuthFailureWindow)}
+		basicAuth.failures[key] = failures
+	}
+	failures.count++
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// tokenParam matches token query parameters, which carry credentials for
+	// clients that cannot send the Authorization header.
+	tokenParam = regexp.MustCompile(`([?&]token=)[^&]*`)
+	// publishedToken matches the token in the path of a published feed.
+	publishedToken = regexp.MustCompile(`^(/api/published/)[^/?]+`)
+)
+
+// redact hides the credentials in a request path.
+func redact(path string) string {
+	path = publishedToken.ReplaceAllString(path, "${1}REDACTED")
+	return tokenParam.ReplaceAllString(path, "${1}REDACTED")
+}
+
+// Logger 记录请求日志，与 gin 默认格式相同，但隐藏查询参数和已发布订阅源路径中的 token
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redact(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
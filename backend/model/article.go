@@ -2,21 +2,68 @@ package model
 
 import "time"
 
+// Article extraction statuses.
+const (
+	ArticlePending = "pending"
+	ArticleReady   = "ready"
+	ArticleFailed  = "failed"
+)
+
 // Article represents a saved article.
 type Article struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	URL       string    `json:"url"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Excerpt   string    `json:"excerpt"`
-	ImageURL  string    `json:"image_url"`
-	CreatedAt time.Time `json:"created_at"`
-	Tags      []Tag     `json:"tags"`
+	ID          int              `json:"id"`
+	UserID      int              `json:"user_id"`
+	URL         string           `json:"url"`
+	Title       string           `json:"title"`
+	Content     string           `json:"content"`
+	ContentHTML string           `json:"content_html"` // 经过清洗的 HTML 正文
+	Markdown    string           `json:"-"`            // Markdown 正文，通过 ?format=markdown 获取
+	Excerpt     string           `json:"excerpt"`
+	ImageURL    string           `json:"image_url"`
+	Domain      string           `json:"domain"`
+	ReadingTime int              `json:"reading_time"` // 预计阅读时间（分钟）
+	Status      string           `json:"status"`       // 内容提取状态
+	CreatedAt   time.Time        `json:"created_at"`
+	RefreshedAt *time.Time       `json:"refreshed_at"` // 最近一次重新提取的时间
+	IsRead      bool             `json:"is_read"`
+	ReadAt      *time.Time       `json:"read_at"`
+	IsArchived  bool             `json:"is_archived"`
+	ArchivedAt  *time.Time       `json:"archived_at"`
+	IsFavorite  bool             `json:"is_favorite"`
+	FavoritedAt *time.Time       `json:"favorited_at"`
+	Progress    *ReadingProgress `json:"progress"`
+	Snippet     string           `json:"snippet,omitempty"` // 全文搜索命中的高亮片段
+	Tags        []Tag            `json:"tags"`
+}
+
+// ArticlePage represents one page of a paginated article list.
+type ArticlePage struct {
+	Articles   []Article `json:"articles"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"next_cursor"` // 为空表示没有更多结果
+}
+
+// ArticleStateUpdate represents a partial update of an article's read,
+// archived and favorite flags. Nil fields are left unchanged.
+type ArticleStateUpdate struct {
+	IsRead     *bool `json:"is_read"`
+	IsArchived *bool `json:"is_archived"`
+	IsFavorite *bool `json:"is_favorite"`
+}
+
+// ReadingProgress records how far a user has read into an article so reading
+// can be resumed on another device.
+type ReadingProgress struct {
+	ArticleID  int       `json:"article_id"`
+	Percentage float64   `json:"percentage"` // 滚动百分比，0-100
+	Offset     int       `json:"offset"`     // Content 中的字符偏移量
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // Tag represents a tag for an article.
 type Tag struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// ArticleCount is only filled in when listing a user's tags.
+	ArticleCount int `json:"article_count,omitempty"`
 }
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Asset kinds.
+const (
+	AssetImage      = "image"      // 文章中的图片
+	AssetSnapshot   = "snapshot"   // 单文件 HTML 页面快照
+	AssetPDF        = "pdf"        // 打印为 PDF 的页面
+	AssetScreenshot = "screenshot" // 整页截图
+	AssetThumbnail  = "thumbnail"  // 页面顶部的缩略图
+	AssetWARC       = "warc"       // 抓取页面时的 WARC 归档
+)
+
+// Asset is a file stored for an article, such as one of its images. The file
+// is addressed by the SHA-256 hash of its content.
+type Asset struct {
+	ArticleID int    `json:"article_id"`
+	Hash      string `json:"hash"`
+	Kind      string `json:"kind"`
+	MediaType string `json:"media_type"`
+	// Encoding is the content coding the file is stored with, such as gzip.
+	Encoding  string    `json:"encoding,omitempty"`
+	Size      int64     `json:"size"`
+	SourceURL string    `json:"source_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
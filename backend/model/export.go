@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// ExportFormat identifies the JSON exports of Read It Later.
+const ExportFormat = "read-it-later"
+
+// ExportVersion is the version of the JSON export schema. It changes when
+// fields are removed or change meaning; fields may be added within a version.
+const ExportVersion = 1
+
+// LibraryExport is the JSON export of a user's library. Articles hold
+// everything needed to restore them on another instance; IDs are left out
+// because they differ between instances. Image URLs point to the original
+// images rather than the copies stored by the exporting instance.
+type LibraryExport struct {
+	Format     string            `json:"format"`  // 固定为 "read-it-later"
+	Version    int               `json:"version"` // ExportVersion
+	ExportedAt time.Time         `json:"exported_at"`
+	Articles   []ExportedArticle `json:"articles"`
+}
+
+// ExportedArticle is an article in a LibraryExport, with its tags, reading
+// progress and highlights.
+type ExportedArticle struct {
+	URL         string              `json:"url"`
+	Title       string              `json:"title"`
+	Excerpt     string              `json:"excerpt"`
+	ImageURL    string              `json:"image_url"`
+	Domain      string              `json:"domain"`
+	Content     string              `json:"content"`
+	ContentHTML string              `json:"content_html"`
+	Markdown    string              `json:"markdown"`
+	ReadingTime int                 `json:"reading_time"`
+	Status      string              `json:"status"` // 非 ready 的文章导入后重新提取
+	CreatedAt   time.Time           `json:"created_at"`
+	RefreshedAt *time.Time          `json:"refreshed_at"`
+	IsRead      bool                `json:"is_read"`
+	ReadAt      *time.Time          `json:"read_at"`
+	IsArchived  bool                `json:"is_archived"`
+	ArchivedAt  *time.Time          `json:"archived_at"`
+	IsFavorite  bool                `json:"is_favorite"`
+	FavoritedAt *time.Time          `json:"favorited_at"`
+	Tags        []string            `json:"tags"`
+	Progress    *ExportedProgress   `json:"progress"`
+	Highlights  []ExportedHighlight `json:"highlights"`
+}
+
+// ExportedProgress is the reading progress of an exported article.
+type ExportedProgress struct {
+	Percentage float64   `json:"percentage"`
+	Offset     int       `json:"offset"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ExportedHighlight is a highlight of an exported article, anchored like
+// Highlight.
+type ExportedHighlight struct {
+	StartOffset int       `json:"start_offset"`
+	EndOffset   int       `json:"end_offset"`
+	Quote       string    `json:"quote"`
+	Prefix      string    `json:"prefix"`
+	Suffix      string    `json:"suffix"`
+	Note        string    `json:"note"`
+	Color       string    `json:"color"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Feed is a user's subscription to an RSS, Atom or JSON Feed. New entries are
+// saved as articles with the feed's tags.
+type Feed struct {
+	ID      int      `json:"id"`
+	UserID  int      `json:"user_id"`
+	URL     string   `json:"url"`
+	Title   string   `json:"title"`
+	SiteURL string   `json:"site_url"`
+	Tags    []string `json:"tags"` // 新文章自动添加的标签
+	// ETag and LastModified are the validators of the last response, sent
+	// with the next poll so unchanged feeds are not downloaded again.
+	ETag         string     `json:"-"`
+	LastModified string     `json:"-"`
+	LastPolledAt *time.Time `json:"last_polled_at"`
+	NextPollAt   time.Time  `json:"next_poll_at"`
+	Failures     int        `json:"failures"` // 连续失败的次数
+	LastError    string     `json:"last_error,omitempty"`
+	EntryCount   int        `json:"entry_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// FeedEntry is an entry seen in a feed. Entries are identified by their GUID
+// so each one is saved once, even if the feed changes its content.
+type FeedEntry struct {
+	ID          int        `json:"id"`
+	FeedID      int        `json:"feed_id"`
+	GUID        string     `json:"guid"`
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	PublishedAt *time.Time `json:"published_at"`
+	// ArticleID is the article the entry was saved as, or nil if it was
+	// not saved, such as entries already in the feed when subscribing.
+	ArticleID *int      `json:"article_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
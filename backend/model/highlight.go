@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Highlight represents a highlighted passage of an article's Content.
+//
+// StartOffset and EndOffset are character offsets into Content. Quote, Prefix
+// and Suffix form a text-quote selector that is used to re-anchor the
+// highlight when the offsets no longer match, e.g. after re-extraction.
+type Highlight struct {
+	ID          int       `json:"id"`
+	ArticleID   int       `json:"article_id"`
+	StartOffset int       `json:"start_offset"`
+	EndOffset   int       `json:"end_offset"`
+	Quote       string    `json:"quote"`
+	Prefix      string    `json:"prefix"`
+	Suffix      string    `json:"suffix"`
+	Note        string    `json:"note"`
+	Color       string    `json:"color"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
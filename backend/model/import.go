@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Import statuses.
+const (
+	ImportReading = "reading" // 正在读取导出文件，文章随读随存
+	ImportRunning = "running"
+	ImportDone    = "done"
+)
+
+// Import item statuses.
+const (
+	ImportItemPending  = "pending"
+	ImportItemImported = "imported"
+	ImportItemSkipped  = "skipped" // 已保存过相同 URL 的文章
+	ImportItemFailed   = "failed"
+)
+
+// Import represents a file of articles exported from another service being
+// imported in the background.
+type Import struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Format     string     `json:"format"`
+	Filename   string     `json:"filename"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Pending    int        `json:"pending"`
+	Imported   int        `json:"imported"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ImportItem is one article of an import and the outcome of importing it.
+type ImportItem struct {
+	ID         int        `json:"id"`
+	ImportID   int        `json:"import_id"`
+	URL        string     `json:"url"`
+	Title      string     `json:"title"`
+	Tags       []string   `json:"tags"`
+	SavedAt    *time.Time `json:"saved_at"` // 在原服务中保存的时间
+	IsRead     bool       `json:"is_read"`
+	IsArchived bool       `json:"is_archived"`
+	IsFavorite bool       `json:"is_favorite"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	ArticleID  *int       `json:"article_id"`
+	// ArticleStatus is the extraction status of the imported article.
+	ArticleStatus string `json:"article_status,omitempty"`
+	// Exported is the whole article for items of a Read It Later export,
+	// which are restored as they are instead of being extracted again.
+	Exported *ExportedArticle `json:"-"`
+}
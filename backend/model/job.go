@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Job kinds.
+const (
+	JobKindExtract        = "extract"
+	JobKindRefresh        = "refresh"
+	JobKindRefreshBrowser = "refresh_browser" // 强制使用无头浏览器重新提取
+	JobKindCapture        = "capture"         // 用无头浏览器保存页面快照
+)
+
+// Job statuses.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job represents a background job, such as extracting the content of a saved
+// article. Jobs are persisted so they survive server restarts.
+type Job struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	ArticleID   int       `json:"article_id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	RunAt       time.Time `json:"run_at"` // 下次可执行时间，用于重试退避
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
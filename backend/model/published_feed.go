@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// PublishedFeed is an Atom and RSS feed of a user's saved articles, optionally
+// only those with a tag or in a reading state. Feed readers cannot send the
+// user's token, so the feed is read with an unguessable token in its URL,
+// which can be rotated to revoke access.
+type PublishedFeed struct {
+	ID      int    `json:"id"`
+	UserID  int    `json:"user_id"`
+	Title   string `json:"title"`
+	TagID   *int   `json:"tag_id"`
+	TagName string `json:"tag_name,omitempty"`
+	State   string `json:"state"` // unread、read、archived，为空表示全部文章
+	Token   string `json:"token"`
+	// AtomURL and RSSURL are the addresses of the feed, filled in by the
+	// handlers.
+	AtomURL   string    `json:"atom_url,omitempty"`
+	RSSURL    string    `json:"rss_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// TokenRotatedAt is when the token was last replaced.
+	TokenRotatedAt *time.Time `json:"token_rotated_at"`
+}
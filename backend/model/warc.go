@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// WARCRecord locates a record in the WARC archive of an article, like a line
+// of a CDX index.
+type WARCRecord struct {
+	ID        int    `json:"id"`
+	ArticleID int    `json:"article_id"`
+	Hash      string `json:"hash"`
+	RecordID  string `json:"record_id"`
+	Type      string `json:"type"`
+	TargetURI string `json:"target_uri,omitempty"`
+	// Status and MediaType describe the HTTP response of response records.
+	Status        int       `json:"status,omitempty"`
+	MediaType     string    `json:"media_type,omitempty"`
+	PayloadDigest string    `json:"payload_digest,omitempty"`
+	Offset        int64     `json:"offset"`
+	Length        int64     `json:"length"`
+	RecordedAt    time.Time `json:"recorded_at"`
+}
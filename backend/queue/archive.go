@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"read-it-later/backend/assets"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+	"read-it-later/backend/warc"
+)
+
+// WARC recording modes.
+const (
+	recordNone    = "none"
+	recordHTTP    = "http"
+	recordBrowser = "browser"
+)
+
+// warcSoftware names the software in the warcinfo record of archives.
+const warcSoftware = "read-it-later"
+
+var (
+	recordingMu   sync.RWMutex
+	recordingMode = recordHTTP
+)
+
+// SetWARCRecording sets what is recorded in the WARC archives of articles.
+// "http" records the pages fetched to extract articles, "browser" records
+// the network traffic of the headless browser as well, and "none" disables
+// archiving.
+func SetWARCRecording(mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case recordNone, recordHTTP, recordBrowser:
+	case "":
+		mode = recordNone
+	default:
+		return fmt.Errorf("unknown WARC recording mode %q", mode)
+	}
+
+	recordingMu.Lock()
+	defer recordingMu.Unlock()
+	recordingMode = mode
+	return nil
+}
+
+// recordingContext returns a context that makes extraction record its
+// traffic in the returned recorder, or a plain context and a nil recorder
+// if archiving is disabled.
+func recordingContext() (context.Context, *warc.Recorder) {
+	recordingMu.RLock()
+	mode := recordingMode
+	recordingMu.RUnlock()
+
+	if mode == recordNone {
+		return context.Background(), nil
+	}
+	recorder := warc.NewRecorder()
+	return extractor.WithRecorder(context.Background(), recorder, mode == recordBrowser), recorder
+}
+
+// archiveArticle writes the traffic recorded while extracting an article to
+// a WARC file and replaces the article's archive with it. Failing to archive
+// does not fail the extraction.
+func archiveArticle(article model.Article, recorder *warc.Recorder) {
+	if recorder == nil || recorder.Len() == 0 {
+		return
+	}
+
+	var b bytes.Buffer
+	entries, err := recorder.Write(&b, warcSoftware)
+	if err != nil {
+		log.Printf("Error writing WARC of article %d: %v", article.ID, err)
+		return
+	}
+
+	hash, err := assets.Put(b.Bytes())
+	if err != nil {
+		log.Printf("Error storing WARC of article %d: %v", article.ID, err)
+		return
+	}
+	defer assets.Release(hash)
+
+	records := make([]model.WARCRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = model.WARCRecord{
+			RecordID:      entry.RecordID,
+			Type:          entry.Type,
+			TargetURI:     entry.TargetURI,
+			Status:        entry.Status,
+			MediaType:     entry.MediaType,
+			PayloadDigest: entry.PayloadDigest,
+			Offset:        entry.Offset,
+			Length:        entry.Length,
+			RecordedAt:    entry.Date,
+		}
+	}
+
+	archive := model.Asset{
+		Hash:      hash,
+		Kind:      model.AssetWARC,
+		MediaType: "application/gzip",
+		Size:      int64(b.Len()),
+		SourceURL: article.URL,
+	}
+	if err := store.SetArticleWARC(article.ID, archive, records); err != nil {
+		log.Printf("Error saving WARC of article %d: %v", article.ID, err)
+	}
+}
@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"read-it-later/backend/assets"
+	"read-it-later/backend/events"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+)
+
+var (
+	captureMu   sync.RWMutex
+	captureOpts = extractor.CaptureOptions{Snapshot: true}
+)
+
+// SetCaptureModes sets the forms pages are captured in after their content is
+// extracted. "snapshot" keeps a self-contained HTML copy of the page, "pdf"
+// prints it to PDF and "screenshot" takes a full-page screenshot and a
+// thumbnail; "none" or an empty list disables capturing.
+func SetCaptureModes(modes []string) error {
+	var opts extractor.CaptureOptions
+	for _, mode := range modes {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "snapshot":
+			opts.Snapshot = true
+		case "pdf":
+			opts.PDF = true
+		case "screenshot":
+			opts.Screenshot = true
+		case "none", "":
+		default:
+			return fmt.Errorf("unknown capture mode %q", mode)
+		}
+	}
+
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	captureOpts = opts
+	return nil
+}
+
+// captureOptions returns the forms pages are captured in, and whether any
+// are enabled.
+func captureOptions() (extractor.CaptureOptions, bool) {
+	captureMu.RLock()
+	defer captureMu.RUnlock()
+	return captureOpts, captureOpts.Snapshot || captureOpts.PDF || captureOpts.Screenshot
+}
+
+// queueCapture queues a job to capture the page of an article, if capturing
+// is enabled.
+func queueCapture(job model.Job) {
+	if _, enabled := captureOptions(); !enabled {
+		return
+	}
+	if _, err := store.QueueJob(job.ArticleID, job.UserID, model.JobKindCapture); err != nil {
+		log.Printf("Error queueing capture of article %d: %v", job.ArticleID, err)
+		return
+	}
+	Notify()
+}
+
+// captureArticle renders the page of an article in a headless browser and
+// stores the captured forms as article assets, replacing earlier captures.
+// Articles without a lead image get the thumbnail of their page instead.
+func captureArticle(job model.Job) error {
+	opts, enabled := captureOptions()
+	if !enabled {
+		return nil
+	}
+
+	article, err := store.GetArticleByID(job.ArticleID, job.UserID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	capture, err := extractor.CapturePage(article.URL, opts)
+	if err != nil {
+		return err
+	}
+
+	previousThumbnails, err := store.GetArticleAssets(article.ID, model.AssetThumbnail)
+	if err != nil {
+		return err
+	}
+
+	captured := []struct {
+		kind      string
+		data      []byte
+		mediaType string
+		compress  bool
+	}{
+		{model.AssetSnapshot, capture.Snapshot, "text/html", true},
+		{model.AssetPDF, capture.PDF, "application/pdf", false},
+		{model.AssetScreenshot, capture.Screenshot, "image/jpeg", false},
+		{model.AssetThumbnail, capture.Thumbnail, "image/jpeg", false},
+	}
+	for _, c := range captured {
+		if c.data == nil {
+			continue
+		}
+
+		var asset model.Asset
+		if c.compress {
+			asset, err = storeCompressed(c.data, c.mediaType, article.URL)
+		} else {
+			asset, err = storeFile(c.data, c.mediaType, article.URL)
+		}
+		if err != nil {
+			return fmt.Errorf("storing %s: %v", c.kind, err)
+		}
+		defer assets.Release(asset.Hash)
+		asset.Kind = c.kind
+		if err := store.SetArticleAssets(article.ID, c.kind, []model.Asset{asset}); err != nil {
+			return err
+		}
+
+		if c.kind == model.AssetThumbnail {
+			replaceable := []string{""}
+			for _, previous := range previousThumbnails {
+				replaceable = append(replaceable, assets.URL(previous.Hash))
+			}
+			updated, err := store.SetArticleImage(article.ID, assets.URL(asset.Hash), replaceable)
+			if err != nil {
+				return err
+			}
+			if updated {
+				publishArticle(job.UserID, events.ArticleExtracted, article.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// storeFile stores data as it is.
+func storeFile(data []byte, mediaType string, sourceURL string) (model.Asset, error) {
+	hash, err := assets.Put(data)
+	if err != nil {
+		return model.Asset{}, err
+	}
+
+	return model.Asset{
+		Hash:      hash,
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+		SourceURL: sourceURL,
+	}, nil
+}
+
+// storeCompressed stores data compressed with gzip.
+func storeCompressed(data []byte, mediaType string, sourceURL string) (model.Asset, error) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return model.Asset{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return model.Asset{}, err
+	}
+
+	hash, err := assets.Put(b.Bytes())
+	if err != nil {
+		return model.Asset{}, err
+	}
+
+	return model.Asset{
+		Hash:      hash,
+		MediaType: mediaType,
+		Encoding:  "gzip",
+		Size:      int64(b.Len()),
+		SourceURL: sourceURL,
+	}, nil
+}
@@ -0,0 +1,302 @@
+// Package queue runs background jobs stored in the jobs table with a bounded
+// pool of workers. Failed jobs are retried with exponential backoff, and jobs
+// interrupted by a restart are picked up again on startup.
+package queue
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"read-it-later/backend/assets"
+	"read-it-later/backend/events"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/model"
+	"read-it-later/backend/store"
+)
+
+const (
+	// pollInterval is how often idle workers look for jobs whose retry delay has passed.
+	pollInterval = 5 * time.Second
+	// baseBackoff is the delay before the first retry; it doubles with each attempt.
+	baseBackoff = 30 * time.Second
+	// maxBackoff caps the delay between retries.
+	maxBackoff = 30 * time.Minute
+)
+
+// wake signals an idle worker that a job has been queued.
+var wake = make(chan struct{}, 1)
+
+// Start requeues jobs interrupted by a previous shutdown and starts the workers.
+func Start(workers int) {
+	requeued, err := store.RequeueInterruptedJobs()
+	if err != nil {
+		log.Printf("Error requeueing interrupted jobs: %v", err)
+	} else if requeued > 0 {
+		log.Printf("Requeued %d interrupted jobs", requeued)
+	}
+
+	for i := 0; i < workers; i++ {
+		go work()
+	}
+	Notify()
+}
+
+// Notify wakes an idle worker to pick up a newly queued job.
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs due jobs until there are none left, then waits to be woken or
+// for the next poll.
+func work() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for runNext() {
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs one due job. It reports whether a job was claimed.
+func runNext() bool {
+	job, err := store.ClaimNextJob()
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error claiming job: %v", err)
+		return false
+	}
+
+	// More jobs may be waiting, so let another idle worker look as well
+	Notify()
+
+	if err := run(job); err != nil {
+		handleFailure(job, err)
+		return true
+	}
+
+	if err := store.CompleteJob(job.ID); err != nil {
+		log.Printf("Error completing job %d: %v", job.ID, err)
+	}
+	return true
+}
+
+// run executes a job according to its kind.
+func run(job model.Job) error {
+	switch job.Kind {
+	case model.JobKindExtract:
+		return extractArticle(job)
+	case model.JobKindRefresh, model.JobKindRefreshBrowser:
+		return refreshArticle(job)
+	case model.JobKindCapture:
+		return captureArticle(job)
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// extractArticle extracts the content of a saved article and stores it.
+func extractArticle(job model.Job) error {
+	article, err := store.GetArticleByID(job.ArticleID, job.UserID)
+	if err == sql.ErrNoRows {
+		// The article was deleted while the job was waiting
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	events.Publish(job.UserID, events.ArticleExtracting, events.JobStarted{
+		ArticleID: article.ID,
+		JobID:     job.ID,
+		Attempt:   job.Attempts,
+	})
+
+	ctx, recorder := recordingContext()
+	extracted, err := extractor.ExtractContext(ctx, article.URL)
+	if err != nil {
+		return err
+	}
+
+	images := storeImages(&extracted)
+	defer releaseAssets(images)
+	if err := store.UpdateExtractedArticle(article.ID, extracted); err != nil {
+		return err
+	}
+	if err := store.SetArticleAssets(article.ID, model.AssetImage, images); err != nil {
+		log.Printf("Error saving images of article %d: %v", article.ID, err)
+	}
+	archiveArticle(article, recorder)
+
+	publishArticle(job.UserID, events.ArticleExtracted, article.ID)
+	queueCapture(job)
+	return nil
+}
+
+// storeImages downloads the images of an extracted article, including its
+// lead image, and points the article to the stored copies so it survives the
+// images being deleted or hotlinking being blocked at the origin. The stored
+// images are released with releaseAssets once the article refers to them.
+func storeImages(extracted *model.Article) []model.Asset {
+	content, images := assets.LocalizeImages(extracted.ContentHTML)
+	extracted.ContentHTML = content
+
+	if extracted.ImageURL != "" {
+		// Some sites give the lead image as a relative URL
+		if base, err := url.Parse(extracted.URL); err == nil {
+			if imageURL, err := base.Parse(extracted.ImageURL); err == nil && !strings.HasPrefix(extracted.ImageURL, "/api/") {
+				extracted.ImageURL = imageURL.String()
+			}
+		}
+
+		image, err := assets.StoreImage(extracted.ImageURL)
+		if err != nil {
+			log.Printf("Error storing image %s: %v", extracted.ImageURL, err)
+		} else {
+			extracted.ImageURL = assets.URL(image.Hash)
+			images = append(images, image)
+		}
+	}
+
+	stored := make([]model.Asset, len(images))
+	for i, image := range images {
+		stored[i] = model.Asset{
+			Hash:      image.Hash,
+			Kind:      model.AssetImage,
+			MediaType: image.MediaType,
+			Size:      image.Size,
+			SourceURL: image.SourceURL,
+		}
+	}
+	return stored
+}
+
+// releaseAssets lets stored assets be removed once the references to them
+// are saved.
+func releaseAssets(stored []model.Asset) {
+	for _, asset := range stored {
+		assets.Release(asset.Hash)
+	}
+}
+
+// publishArticle sends the stored article, without its content, to the user's
+// clients.
+func publishArticle(userID int, eventType string, articleID int) {
+	article, err := store.GetArticleByID(articleID, userID)
+	if err != nil {
+		log.Printf("Error loading article %d for %s event: %v", articleID, eventType, err)
+		return
+	}
+	article.Content = ""
+	article.ContentHTML = ""
+	events.Publish(userID, eventType, article)
+}
+
+// refreshArticle extracts the content of an article again, optionally
+// rendering the page in a headless browser, and replaces the stored version.
+// Pages whose content cannot be found, such as error pages, fail the job and
+// leave the stored content as it was.
+func refreshArticle(job model.Job) error {
+	article, err := store.GetArticleByID(job.ArticleID, job.UserID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	events.Publish(job.UserID, events.ArticleExtracting, events.JobStarted{
+		ArticleID: article.ID,
+		JobID:     job.ID,
+		Attempt:   job.Attempts,
+	})
+
+	ctx, recorder := recordingContext()
+	var extracted model.Article
+	if job.Kind == model.JobKindRefreshBrowser {
+		extracted, err = extractor.ExtractWithBrowserContext(ctx, article.URL)
+	} else {
+		extracted, err = extractor.RefreshContext(ctx, article.URL)
+	}
+	if err != nil {
+		return err
+	}
+
+	images := storeImages(&extracted)
+	defer releaseAssets(images)
+	if err := store.RefreshArticle(article.ID, extracted); err != nil {
+		return err
+	}
+	if err := store.SetArticleAssets(article.ID, model.AssetImage, images); err != nil {
+		log.Printf("Error saving images of article %d: %v", article.ID, err)
+	}
+	archiveArticle(article, recorder)
+
+	publishArticle(job.UserID, events.ArticleExtracted, article.ID)
+	queueCapture(job)
+	return nil
+}
+
+// handleFailure schedules a retry for a failed job, or marks the job as failed
+// once it has no attempts left. Articles whose first extraction failed are
+// marked as failed too; a failed refresh keeps the existing content. Failed
+// page captures are not reported to clients, as the article itself is fine.
+func handleFailure(job model.Job, jobErr error) {
+	log.Printf("Job %d (%s) attempt %d/%d failed: %v", job.ID, job.Kind, job.Attempts, job.MaxAttempts, jobErr)
+
+	if job.Attempts < job.MaxAttempts {
+		if err := store.RetryJob(job.ID, jobErr.Error(), backoff(job.Attempts)); err != nil {
+			log.Printf("Error scheduling retry of job %d: %v", job.ID, err)
+		}
+		if job.Kind != model.JobKindCapture {
+			publishFailure(job, jobErr, true)
+		}
+		return
+	}
+
+	if err := store.FailJob(job.ID, jobErr.Error()); err != nil {
+		log.Printf("Error failing job %d: %v", job.ID, err)
+	}
+	if job.Kind == model.JobKindExtract {
+		if err := store.SetArticleStatus(job.ArticleID, model.ArticleFailed); err != nil {
+			log.Printf("Error updating status of article %d: %v", job.ArticleID, err)
+		}
+	}
+	if job.Kind != model.JobKindCapture {
+		publishFailure(job, jobErr, false)
+	}
+}
+
+// publishFailure tells the user's clients that an attempt to run a job failed.
+func publishFailure(job model.Job, jobErr error, willRetry bool) {
+	events.Publish(job.UserID, events.ArticleFailed, events.JobFailure{
+		ArticleID: job.ArticleID,
+		JobID:     job.ID,
+		Attempt:   job.Attempts,
+		Error:     jobErr.Error(),
+		WillRetry: willRetry,
+	})
+}
+
+// backoff returns the delay before retrying a job that has failed attempts times.
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,95 @@
+// Package rss defines RSS 2.0 documents, with the content module used to
+// carry the full content of items.
+package rss
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	// ContentNamespace is the namespace of the RSS content module.
+	ContentNamespace = "http://purl.org/rss/1.0/modules/content/"
+	// AtomNamespace is the Atom namespace, used for the channel's self link.
+	AtomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+// ContentType is the media type of RSS documents.
+const ContentType = "application/rss+xml; charset=utf-8"
+
+// RSS is an RSS 2.0 document.
+type RSS struct {
+	XMLName      xml.Name `xml:"rss"`
+	Version      string   `xml:"version,attr"`
+	XMLNSContent string   `xml:"xmlns:content,attr"`
+	XMLNSAtom    string   `xml:"xmlns:atom,attr"`
+	Channel      Channel  `xml:"channel"`
+}
+
+// Channel is the channel of an RSS document.
+type Channel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	// SelfLink is the URL of the document itself, as an atom:link.
+	SelfLink      *SelfLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []Item    `xml:"item"`
+}
+
+// SelfLink is an atom:link pointing to the document itself.
+type SelfLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// Item is an item of an RSS channel.
+type Item struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        GUID     `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	// Content is the full HTML content of the item.
+	Content string `xml:"content:encoded,omitempty"`
+}
+
+// GUID identifies an item. IsPermaLink tells whether it is also its URL.
+type GUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// New returns a document with a channel and the namespaces of the
+// extensions declared.
+func New(title, link, description string) *RSS {
+	return &RSS{
+		Version:      "2.0",
+		XMLNSContent: ContentNamespace,
+		XMLNSAtom:    AtomNamespace,
+		Channel:      Channel{Title: title, Link: link, Description: description},
+	}
+}
+
+// FormatTime formats a timestamp as RFC 822, as required by RSS.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+// Write writes the document as XML.
+func (r *RSS) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
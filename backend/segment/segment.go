@@ -0,0 +1,161 @@
+// Package segment splits Chinese text into words using a bundled dictionary.
+//
+// SQLite's unicode61 tokenizer treats a run of Chinese characters as a single
+// token, so the search index needs the words separated before indexing. The
+// segmenter builds a DAG of all dictionary words in a run of Chinese text and
+// picks the most probable path through it, the same approach as jieba.
+//
+// dict.txt is derived from the jieba dictionary (MIT License), trimmed to
+// entries that occur at least five times.
+package segment
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed dict.txt
+var dictData string
+
+// dictionary maps each word to its log probability.
+type dictionary struct {
+	logProb    map[string]float64
+	minLogProb float64
+	maxWordLen int
+}
+
+var (
+	dict     *dictionary
+	dictOnce sync.Once
+)
+
+// loadDictionary parses the bundled dictionary on first use.
+func loadDictionary() *dictionary {
+	dictOnce.Do(func() {
+		freq := make(map[string]float64)
+		total := 0.0
+		maxWordLen := 1
+
+		scanner := bufio.NewScanner(strings.NewReader(dictData))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			count, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || count <= 0 {
+				continue
+			}
+			freq[fields[0]] = count
+			total += count
+			maxWordLen = max(maxWordLen, utf8.RuneCountInString(fields[0]))
+		}
+
+		d := &dictionary{
+			logProb:    make(map[string]float64, len(freq)),
+			minLogProb: 0,
+			maxWordLen: maxWordLen,
+		}
+		for word, count := range freq {
+			lp := math.Log(count / total)
+			d.logProb[word] = lp
+			d.minLogProb = min(d.minLogProb, lp)
+		}
+		dict = d
+	})
+	return dict
+}
+
+// IsHan reports whether r is a Chinese character.
+func IsHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// Cut splits text into words. Runs of Chinese characters are segmented with
+// the dictionary; any other text is returned as-is between them, so joining
+// the result yields the original text.
+func Cut(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+
+	for start := 0; start < len(runes); {
+		han := IsHan(runes[start])
+		end := start + 1
+		for end < len(runes) && IsHan(runes[end]) == han {
+			end++
+		}
+
+		if han {
+			tokens = append(tokens, cutHan(runes[start:end])...)
+		} else {
+			tokens = append(tokens, string(runes[start:end]))
+		}
+		start = end
+	}
+
+	return tokens
+}
+
+// cutHan segments a run of Chinese characters into its most probable
+// sequence of dictionary words. Characters not covered by any dictionary
+// word become single-character words.
+func cutHan(runes []rune) []string {
+	d := loadDictionary()
+	n := len(runes)
+
+	// best[i] holds the highest log probability of segmenting runes[i:],
+	// and next[i] the end of the first word on that path
+	best := make([]float64, n+1)
+	next := make([]int, n+1)
+
+	for i := n - 1; i >= 0; i-- {
+		best[i] = math.Inf(-1)
+		for j := i + 1; j <= n && j-i <= d.maxWordLen; j++ {
+			lp, ok := d.logProb[string(runes[i:j])]
+			if !ok {
+				if j != i+1 {
+					continue
+				}
+				lp = d.minLogProb
+			}
+			if score := lp + best[j]; score > best[i] {
+				best[i] = score
+				next[i] = j
+			}
+		}
+	}
+
+	words := make([]string, 0, n)
+	for i := 0; i < n; i = next[i] {
+		words = append(words, string(runes[i:next[i]]))
+	}
+	return words
+}
+
+// SubWords returns the two- and three-character dictionary words contained in
+// a longer Chinese word, so that searching for part of a compound word such as
+// "智能" in "人工智能" still finds it.
+func SubWords(word string) []string {
+	d := loadDictionary()
+	runes := []rune(word)
+
+	var words []string
+	for _, size := range []int{2, 3} {
+		if len(runes) <= size {
+			continue
+		}
+		for i := 0; i+size <= len(runes); i++ {
+			gram := string(runes[i : i+size])
+			if _, ok := d.logProb[gram]; ok {
+				words = append(words, gram)
+			}
+		}
+	}
+	return words
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"database/sql"
+	"log"
+	"read-it-later/backend/assets"
+	"read-it-later/backend/model"
+)
+
+// SetArticleAssets replaces the assets of one kind stored for an article,
+// such as its images after a refresh. Files no article refers to any more are
+// removed from disk.
+func SetArticleAssets(articleID int, kind string, stored []model.Asset) error {
+	previous, err := articleAssetHashes(articleID, kind)
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceArticleAssets(tx, articleID, kind, stored); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	removeOrphanAssets(previous)
+	return nil
+}
+
+// replaceArticleAssets replaces the rows of an article's assets of one kind.
+func replaceArticleAssets(tx *sql.Tx, articleID int, kind string, stored []model.Asset) error {
+	if _, err := tx.Exec("DELETE FROM article_assets WHERE article_id = ? AND kind = ?", articleID, kind); err != nil {
+		return err
+	}
+
+	for _, asset := range stored {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO article_assets(article_id, hash, kind, media_type, encoding, size, source_url)
+			VALUES(?, ?, ?, ?, ?, ?, ?)`,
+			articleID, asset.Hash, kind, asset.MediaType, asset.Encoding, asset.Size, asset.SourceURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetArticleAssets retrieves the assets of one kind stored for an article.
+func GetArticleAssets(articleID int, kind string) ([]model.Asset, error) {
+	rows, err := DB.Query(`
+		SELECT article_id, hash, kind, media_type, encoding, size, source_url, created_at
+		FROM article_assets
+		WHERE article_id = ? AND kind = ?
+		ORDER BY created_at, hash`, articleID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := []model.Asset{}
+	for rows.Next() {
+		var asset model.Asset
+		if err := rows.Scan(&asset.ArticleID, &asset.Hash, &asset.Kind, &asset.MediaType, &asset.Encoding, &asset.Size, &asset.SourceURL, &asset.CreatedAt); err != nil {
+			return nil, err
+		}
+		stored = append(stored, asset)
+	}
+
+	return stored, rows.Err()
+}
+
+// GetAssetForUser retrieves an asset stored for one of the user's articles.
+// It returns sql.ErrNoRows if none of the user's articles refers to it.
+func GetAssetForUser(hash string, userID int) (model.Asset, error) {
+	var asset model.Asset
+	err := DB.QueryRow(`
+		SELECT aa.article_id, aa.hash, aa.kind, aa.media_type, aa.encoding, aa.size, aa.source_url, aa.created_at
+		FROM article_assets aa
+		JOIN articles a ON a.id = aa.article_id
+		WHERE aa.hash = ? AND a.user_id = ?
+		LIMIT 1`, hash, userID).Scan(
+		&asset.ArticleID, &asset.Hash, &asset.Kind, &asset.MediaType, &asset.Encoding, &asset.Size, &asset.SourceURL, &asset.CreatedAt)
+	return asset, err
+}
+
+// articleAssetHashes returns the hashes of an article's assets of one kind,
+// or of all kinds if kind is empty.
+func articleAssetHashes(articleID int, kind string) ([]string, error) {
+	rows, err := DB.Query(`
+		SELECT hash FROM article_assets
+		WHERE article_id = ? AND (? = '' OR kind = ?)`, articleID, kind, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// removeOrphanAssets deletes the files of the given assets that no article
+// refers to any more.
+func removeOrphanAssets(hashes []string) {
+	for _, hash := range hashes {
+		_, err := assets.RemoveUnused(hash, func() (bool, error) {
+			var referenced bool
+			err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM article_assets WHERE hash = ?)", hash).Scan(&referenced)
+			return referenced, err
+		})
+		if err != nil {
+			log.Printf("Error removing asset %s: %v", hash, err)
+		}
+	}
+}
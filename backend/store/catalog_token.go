@@ -0,0 +1,54 @@
+package store
+
+import (
+	"database/sql"
+	"read-it-later/backend/model"
+)
+
+// GetUserByCatalogToken retrieves the user whose OPDS catalog token is token.
+func GetUserByCatalogToken(token string) (*model.User, error) {
+	var user model.User
+	err := DB.QueryRow("SELECT id, username, email, password, created_at FROM users WHERE catalog_token = ?", token).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetCatalogToken retrieves the user's OPDS catalog token, or an empty string
+// if they have none.
+func GetCatalogToken(userID int) (string, error) {
+	var token sql.NullString
+	if err := DB.QueryRow("SELECT catalog_token FROM users WHERE id = ?", userID).Scan(&token); err != nil {
+		return "", err
+	}
+	return token.String, nil
+}
+
+// RotateCatalogToken gives the user a new OPDS catalog token, replacing the
+// previous one, and returns it.
+func RotateCatalogToken(userID int) (string, error) {
+	token, err := newURLToken()
+	if err != nil {
+		return "", err
+	}
+
+	res, err := DB.Exec("UPDATE users SET catalog_token = ? WHERE id = ?", token, userID)
+	if err != nil {
+		return "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return "", err
+	} else if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	return token, nil
+}
+
+// RevokeCatalogToken removes the user's OPDS catalog token, so catalog URLs
+// carrying it stop working.
+func RevokeCatalogToken(userID int) error {
+	_, err := DB.Exec("UPDATE users SET catalog_token = NULL WHERE id = ?", userID)
+	return err
+}
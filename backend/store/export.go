@@ -0,0 +1,77 @@
+package store
+
+import (
+	"read-it-later/backend/model"
+	"strings"
+)
+
+// exportBatchSize is the number of articles EachArticle loads per query.
+const exportBatchSize = 100
+
+// EachArticle calls fn for every article of a user matching filter, oldest
+// first, with its content and tags. Articles are loaded in batches so exports
+// of any size do not hold all of them in memory. Iteration stops at the first
+// error returned by fn, which EachArticle returns.
+func EachArticle(userID int, filter ArticleFilter, fn func(model.Article) error) error {
+	sortColumn := sortColumns["created_at"]
+
+	var afterKey interface{}
+	afterID := 0
+	for {
+		conditions, args := filterConditions(userID, filter)
+		if afterID != 0 {
+			conditions = append(conditions, "("+sortColumn.orderBy+" > ? OR ("+sortColumn.orderBy+" = ? AND a.id > ?))")
+			args = append(args, afterKey, afterKey, afterID)
+		}
+		args = append(args, exportBatchSize)
+
+		articles, lastKey, err := exportBatch(conditions, args, sortColumn.key, sortColumn.orderBy)
+		if err != nil {
+			return err
+		}
+
+		if err := attachTags(articles); err != nil {
+			return err
+		}
+
+		for _, article := range articles {
+			if err := fn(article); err != nil {
+				return err
+			}
+		}
+
+		if len(articles) < exportBatchSize {
+			return nil
+		}
+		afterKey, afterID = lastKey, articles[len(articles)-1].ID
+	}
+}
+
+// exportBatch loads one batch of articles for EachArticle and returns them
+// with the sort key of the last one.
+func exportBatch(conditions []string, args []interface{}, key, orderBy string) ([]model.Article, interface{}, error) {
+	rows, err := DB.Query(`
+		SELECT `+articleColumns+`,
+			`+key+`, COALESCE(a.content, ''), a.content_html, a.markdown
+		FROM articles a
+		LEFT JOIN reading_progress p ON p.article_id = a.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+orderBy+` ASC, a.id ASC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var articles []model.Article
+	var lastKey interface{}
+	for rows.Next() {
+		var article model.Article
+		if err := scanArticle(rows, &article, &lastKey, &article.Content, &article.ContentHTML, &article.Markdown); err != nil {
+			return nil, nil, err
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, lastKey, rows.Err()
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"read-it-later/backend/model"
+	"time"
+)
+
+// ErrFeedExists is returned when subscribing a user to a feed URL they are
+// already subscribed to.
+var ErrFeedExists = errors.New("already subscribed to feed")
+
+// feedColumns lists the columns read by scanFeed.
+const feedColumns = `f.id, f.user_id, f.url, f.title, f.site_url, f.tags, f.etag, f.last_modified, f.last_polled_at,
+	f.next_poll_at, f.failures, f.last_error, f.created_at,
+	(SELECT COUNT(*) FROM feed_entries WHERE feed_id = f.id)`
+
+// scanFeed scans a row selected with feedColumns.
+func scanFeed(row rowScanner) (model.Feed, error) {
+	var feed model.Feed
+	var tags string
+	var lastPolledAt sql.NullTime
+	err := row.Scan(&feed.ID, &feed.UserID, &feed.URL, &feed.Title, &feed.SiteURL, &tags, &feed.ETag, &feed.LastModified,
+		&lastPolledAt, &feed.NextPollAt, &feed.Failures, &feed.LastError, &feed.CreatedAt, &feed.EntryCount)
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &feed.Tags); err != nil {
+		return model.Feed{}, err
+	}
+	feed.LastPolledAt = nullTimePtr(lastPolledAt)
+	return feed, nil
+}
+
+// queryFeeds runs a query selecting feedColumns and scans the feeds.
+func queryFeeds(query string, args ...interface{}) ([]model.Feed, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []model.Feed{}
+	for rows.Next() {
+		feed, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// CreateFeed subscribes a user to a feed, to be polled first at its
+// NextPollAt. It returns ErrFeedExists if the user is already subscribed to
+// its URL.
+func CreateFeed(feed model.Feed) (model.Feed, error) {
+	tags, err := json.Marshal(feed.Tags)
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return model.Feed{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM feeds WHERE user_id = ? AND url = ?)", feed.UserID, feed.URL).Scan(&exists)
+	if err != nil {
+		return model.Feed{}, err
+	}
+	if exists {
+		return model.Feed{}, ErrFeedExists
+	}
+
+	res, err := tx.Exec("INSERT INTO feeds(user_id, url, title, site_url, tags, next_poll_at) VALUES(?, ?, ?, ?, ?, ?)",
+		feed.UserID, feed.URL, feed.Title, feed.SiteURL, string(tags), FormatTimestamp(feed.NextPollAt))
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.Feed{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Feed{}, err
+	}
+
+	return GetFeedByID(int(id), feed.UserID)
+}
+
+// GetFeedByID retrieves one of the user's feeds.
+func GetFeedByID(id int, userID int) (model.Feed, error) {
+	return scanFeed(DB.QueryRow("SELECT "+feedColumns+" FROM feeds f WHERE f.id = ? AND f.user_id = ?", id, userID))
+}
+
+// GetFeedsForUser retrieves the user's feeds ordered by title.
+func GetFeedsForUser(userID int) ([]model.Feed, error) {
+	return queryFeeds("SELECT "+feedColumns+" FROM feeds f WHERE f.user_id = ? ORDER BY f.title COLLATE NOCASE, f.id", userID)
+}
+
+// GetDueFeeds retrieves up to limit feeds of all users that are due to be
+// polled, the longest overdue first.
+func GetDueFeeds(limit int) ([]model.Feed, error) {
+	return queryFeeds("SELECT "+feedColumns+" FROM feeds f WHERE f.next_poll_at <= CURRENT_TIMESTAMP ORDER BY f.next_poll_at LIMIT ?", limit)
+}
+
+// UpdateFeed changes the title and tags of one of the user's feeds. Nil
+// fields are left unchanged.
+func UpdateFeed(id int, userID int, title *string, tags []string) (model.Feed, error) {
+	var tagsJSON interface{}
+	if tags != nil {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return model.Feed{}, err
+		}
+		tagsJSON = string(data)
+	}
+
+	res, err := DB.Exec("UPDATE feeds SET title = COALESCE(?, title), tags = COALESCE(?, tags) WHERE id = ? AND user_id = ?",
+		title, tagsJSON, id, userID)
+	if err != nil {
+		return model.Feed{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return model.Feed{}, err
+	} else if n == 0 {
+		return model.Feed{}, sql.ErrNoRows
+	}
+
+	return GetFeedByID(id, userID)
+}
+
+// DeleteFeed unsubscribes a user from a feed. Articles saved from it are kept.
+func DeleteFeed(id int, userID int) error {
+	res, err := DB.Exec("DELETE FROM feeds WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ScheduleFeedPoll makes one of the user's feeds due to be polled now.
+func ScheduleFeedPoll(id int, userID int) error {
+	res, err := DB.Exec("UPDATE feeds SET next_poll_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FeedPolled records a successful poll of a feed: the validators for the next
+// conditional request, the feed's title and site if given, and when to poll
+// it next. It resets the failure count.
+func FeedPolled(feed model.Feed, next time.Time) error {
+	_, err := DB.Exec(`
+		UPDATE feeds
+		SET etag = ?, last_modified = ?, title = CASE WHEN title = '' THEN ? ELSE title END,
+			site_url = CASE WHEN ? != '' THEN ? ELSE site_url END,
+			last_polled_at = CURRENT_TIMESTAMP, next_poll_at = ?, failures = 0, last_error = ''
+		WHERE id = ?`,
+		feed.ETag, feed.LastModified, feed.Title, feed.SiteURL, feed.SiteURL, FormatTimestamp(next), feed.ID)
+	return err
+}
+
+// FeedPollFailed records a failed poll of a feed and when to try again.
+func FeedPollFailed(id int, errMsg string, next time.Time) error {
+	_, err := DB.Exec(`
+		UPDATE feeds
+		SET last_polled_at = CURRENT_TIMESTAMP, next_poll_at = ?, failures = failures + 1, last_error = ?
+		WHERE id = ?`, FormatTimestamp(next), errMsg, id)
+	return err
+}
+
+// AddFeedEntry records an entry of a feed. It returns the ID of the entry and
+// whether it is new, that is no entry of the feed had its GUID yet.
+func AddFeedEntry(entry model.FeedEntry) (int, bool, error) {
+	res, err := DB.Exec(`
+		INSERT OR IGNORE INTO feed_entries(feed_id, guid, url, title, published_at)
+		VALUES(?, ?, ?, ?, ?)`,
+		entry.FeedID, entry.GUID, entry.URL, entry.Title, importTimestamp(entry.PublishedAt))
+	if err != nil {
+		return 0, false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	return int(id), true, nil
+}
+
+// DeleteFeedEntry forgets an entry of a feed, so it is new again the next
+// time the feed is polled.
+func DeleteFeedEntry(entryID int) error {
+	_, err := DB.Exec("DELETE FROM feed_entries WHERE id = ?", entryID)
+	return err
+}
+
+// SetFeedEntryArticle records the article a feed entry was saved as.
+func SetFeedEntryArticle(entryID int, articleID int) error {
+	_, err := DB.Exec("UPDATE feed_entries SET article_id = ? WHERE id = ?", articleID, entryID)
+	return err
+}
+
+// GetFeedEntries retrieves up to limit entries of one of the user's feeds,
+// newest first.
+func GetFeedEntries(feedID int, userID int, limit int) ([]model.FeedEntry, error) {
+	rows, err := DB.Query(`
+		SELECT e.id, e.feed_id, e.guid, e.url, e.title, e.published_at, e.article_id, e.created_at
+		FROM feed_entries e
+		JOIN feeds f ON f.id = e.feed_id
+		WHERE e.feed_id = ? AND f.user_id = ?
+		ORDER BY e.id DESC
+		LIMIT ?`, feedID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.FeedEntry{}
+	for rows.Next() {
+		var entry model.FeedEntry
+		var publishedAt sql.NullTime
+		var articleID sql.NullInt64
+		err := rows.Scan(&entry.ID, &entry.FeedID, &entry.GUID, &entry.URL, &entry.Title, &publishedAt, &articleID,
+			&entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.PublishedAt = nullTimePtr(publishedAt)
+		if articleID.Valid {
+			id := int(articleID.Int64)
+			entry.ArticleID = &id
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
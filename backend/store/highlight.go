@@ -0,0 +1,277 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"read-it-later/backend/model"
+	"slices"
+)
+
+// highlightContextLength is the number of characters stored before and after
+// a highlight's quote to disambiguate repeated passages.
+const highlightContextLength = 32
+
+// ErrInvalidHighlight is returned when a highlight cannot be anchored to the
+// article's content.
+var ErrInvalidHighlight = errors.New("highlight does not match article content")
+
+// highlightColors lists the colors a highlight may use.
+var highlightColors = []string{"yellow", "green", "blue", "pink", "purple"}
+
+// IsValidHighlightColor reports whether color is a supported highlight color.
+func IsValidHighlightColor(color string) bool {
+	for _, c := range highlightColors {
+		if c == color {
+			return true
+		}
+	}
+	return false
+}
+
+// getArticleContent retrieves the text content of an article owned by a user.
+func getArticleContent(articleID int, userID int) (string, error) {
+	var content sql.NullString
+	err := DB.QueryRow("SELECT content FROM articles WHERE id = ? AND user_id = ?", articleID, userID).Scan(&content)
+	if err != nil {
+		return "", err
+	}
+	return content.String, nil
+}
+
+// GetHighlightsForArticle retrieves all highlights of an article, re-anchored
+// against the article's current content.
+func GetHighlightsForArticle(articleID int, userID int) ([]model.Highlight, error) {
+	content, err := getArticleContent(articleID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT id, article_id, start_offset, end_offset, quote, prefix, suffix, note, color, created_at, updated_at
+		FROM highlights
+		WHERE article_id = ? AND user_id = ?
+		ORDER BY start_offset`, articleID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contentRunes := []rune(content)
+	highlights := []model.Highlight{}
+	for rows.Next() {
+		var h model.Highlight
+		if err := rows.Scan(&h.ID, &h.ArticleID, &h.StartOffset, &h.EndOffset, &h.Quote, &h.Prefix, &h.Suffix, &h.Note, &h.Color, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			return nil, err
+		}
+		// Highlights that can no longer be found keep their stored offsets
+		anchorHighlight(contentRunes, &h)
+		highlights = append(highlights, h)
+	}
+
+	return highlights, rows.Err()
+}
+
+// getHighlightByID retrieves a single highlight of an article owned by a user.
+func getHighlightByID(id int, articleID int, userID int) (model.Highlight, error) {
+	var h model.Highlight
+	err := DB.QueryRow(`
+		SELECT id, article_id, start_offset, end_offset, quote, prefix, suffix, note, color, created_at, updated_at
+		FROM highlights
+		WHERE id = ? AND article_id = ? AND user_id = ?`, id, articleID, userID).
+		Scan(&h.ID, &h.ArticleID, &h.StartOffset, &h.EndOffset, &h.Quote, &h.Prefix, &h.Suffix, &h.Note, &h.Color, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return model.Highlight{}, err
+	}
+	return h, nil
+}
+
+// CreateHighlight anchors a new highlight to its article's content and saves it.
+//
+// If a quote is given it is located in the content, using the offsets, prefix
+// and suffix to pick between repeated passages. Otherwise the quote and its
+// context are taken from the content at the given offsets.
+func CreateHighlight(h model.Highlight, userID int) (model.Highlight, error) {
+	content, err := getArticleContent(h.ArticleID, userID)
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	contentRunes := []rune(content)
+	if h.Quote == "" {
+		if h.StartOffset < 0 || h.EndOffset > len(contentRunes) || h.StartOffset >= h.EndOffset {
+			return model.Highlight{}, ErrInvalidHighlight
+		}
+		h.Quote = string(contentRunes[h.StartOffset:h.EndOffset])
+	}
+
+	if !anchorHighlight(contentRunes, &h) {
+		return model.Highlight{}, ErrInvalidHighlight
+	}
+
+	res, err := DB.Exec(`
+		INSERT INTO highlights(article_id, user_id, start_offset, end_offset, quote, prefix, suffix, note, color)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.ArticleID, userID, h.StartOffset, h.EndOffset, h.Quote, h.Prefix, h.Suffix, h.Note, h.Color)
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	return getHighlightByID(int(id), h.ArticleID, userID)
+}
+
+// UpdateHighlight changes the note and/or color of a highlight. Nil fields
+// are left unchanged.
+func UpdateHighlight(id int, articleID int, userID int, note *string, color *string) (model.Highlight, error) {
+	result, err := DB.Exec(`
+		UPDATE highlights
+		SET note = COALESCE(?, note), color = COALESCE(?, color), updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND article_id = ? AND user_id = ?`,
+		note, color, id, articleID, userID)
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	if rowsAffected == 0 {
+		return model.Highlight{}, sql.ErrNoRows
+	}
+
+	return getHighlightByID(id, articleID, userID)
+}
+
+// DeleteHighlight deletes a highlight of an article owned by a user.
+func DeleteHighlight(id int, articleID int, userID int) error {
+	result, err := DB.Exec("DELETE FROM highlights WHERE id = ? AND article_id = ? AND user_id = ?", id, articleID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// reanchorHighlights stores new offsets and context for the highlights of an
+// article whose content has been replaced. Highlights whose quote no longer
+// appears in the content keep their stored offsets.
+func reanchorHighlights(articleID int, content string) error {
+	rows, err := DB.Query(`
+		SELECT id, article_id, start_offset, end_offset, quote, prefix, suffix
+		FROM highlights
+		WHERE article_id = ?`, articleID)
+	if err != nil {
+		return err
+	}
+
+	contentRunes := []rune(content)
+	var anchored []model.Highlight
+	for rows.Next() {
+		var h model.Highlight
+		if err := rows.Scan(&h.ID, &h.ArticleID, &h.StartOffset, &h.EndOffset, &h.Quote, &h.Prefix, &h.Suffix); err != nil {
+			rows.Close()
+			return err
+		}
+		if anchorHighlight(contentRunes, &h) {
+			anchored = append(anchored, h)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, h := range anchored {
+		_, err := tx.Exec("UPDATE highlights SET start_offset = ?, end_offset = ?, prefix = ?, suffix = ? WHERE id = ?",
+			h.StartOffset, h.EndOffset, h.Prefix, h.Suffix, h.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// anchorHighlight locates the highlight's quote in content and updates its
+// offsets and context to match. It returns false if the quote is not found.
+func anchorHighlight(content []rune, h *model.Highlight) bool {
+	quote := []rune(h.Quote)
+	if len(quote) == 0 || len(quote) > len(content) {
+		return false
+	}
+
+	if h.StartOffset < 0 || h.EndOffset > len(content) || h.StartOffset >= h.EndOffset ||
+		string(content[h.StartOffset:h.EndOffset]) != h.Quote {
+		// The offsets are stale: score every occurrence of the quote by how
+		// much of the stored context surrounds it, preferring the closest one
+		prefix := []rune(h.Prefix)
+		suffix := []rune(h.Suffix)
+		best, bestScore, bestDistance := -1, -1, 0
+
+		for i := 0; i+len(quote) <= len(content); i++ {
+			if !slices.Equal(content[i:i+len(quote)], quote) {
+				continue
+			}
+
+			score := commonSuffixLength(content[:i], prefix) + commonPrefixLength(content[i+len(quote):], suffix)
+			distance := i - h.StartOffset
+			if distance < 0 {
+				distance = -distance
+			}
+
+			if score > bestScore || (score == bestScore && distance < bestDistance) {
+				best, bestScore, bestDistance = i, score, distance
+			}
+		}
+
+		if best < 0 {
+			return false
+		}
+
+		h.StartOffset = best
+		h.EndOffset = best + len(quote)
+	}
+
+	h.Prefix = string(content[max(0, h.StartOffset-highlightContextLength):h.StartOffset])
+	h.Suffix = string(content[h.EndOffset:min(len(content), h.EndOffset+highlightContextLength)])
+	return true
+}
+
+// commonPrefixLength returns the number of leading characters a and b share.
+func commonPrefixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// commonSuffixLength returns the number of trailing characters a and b share.
+func commonSuffixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
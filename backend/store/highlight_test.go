@@ -0,0 +1,182 @@
+package store
+
+import (
+	"path/filepath"
+	"read-it-later/backend/model"
+	"testing"
+)
+
+// openTestDB opens an empty temporary database for a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	InitDB(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { DB.Close() })
+}
+
+// createTestUser creates a user and returns its ID.
+func createTestUser(t *testing.T, username string) int {
+	t.Helper()
+	userID, err := CreateUser(model.User{Username: username, Email: username + "@example.com", Password: "x"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return userID
+}
+
+func TestAnchorHighlight(t *testing.T) {
+	const content = "the cat sat. the cat ran. the dog sat."
+
+	tests := []struct {
+		name      string
+		content   string
+		highlight model.Highlight
+		found     bool
+		start     int
+		end       int
+	}{
+		{
+			name:      "offsets match",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 17, EndOffset: 20, Quote: "cat"},
+			found:     true, start: 17, end: 20,
+		},
+		{
+			name:      "context picks repeated quote",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 0, EndOffset: 0, Quote: "the cat", Prefix: "sat. ", Suffix: " ran"},
+			found:     true, start: 13, end: 20,
+		},
+		{
+			name:      "context outweighs distance",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 30, EndOffset: 33, Quote: "sat", Prefix: "the cat ", Suffix: ". the cat"},
+			found:     true, start: 8, end: 11,
+		},
+		{
+			name:      "stale offsets pick closest occurrence",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 30, EndOffset: 33, Quote: "sat"},
+			found:     true, start: 34, end: 37,
+		},
+		{
+			name:      "offsets out of range",
+			content:   content,
+			highlight: model.Highlight{StartOffset: -4, EndOffset: 99, Quote: "dog"},
+			found:     true, start: 30, end: 33,
+		},
+		{
+			name:      "context picks repeated multi-byte quote",
+			content:   "你好，世界。你好，朋友。",
+			highlight: model.Highlight{StartOffset: 3, EndOffset: 5, Quote: "你好", Prefix: "世界。", Suffix: "，朋友"},
+			found:     true, start: 6, end: 8,
+		},
+		{
+			name:      "multi-byte offsets match",
+			content:   "你好，世界。你好，朋友。",
+			highlight: model.Highlight{StartOffset: 9, EndOffset: 11, Quote: "朋友"},
+			found:     true, start: 9, end: 11,
+		},
+		{
+			name:      "quote gone",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 4, EndOffset: 7, Quote: "bird"},
+			found:     false, start: 4, end: 7,
+		},
+		{
+			name:      "empty quote",
+			content:   content,
+			highlight: model.Highlight{StartOffset: 4, EndOffset: 7},
+			found:     false, start: 4, end: 7,
+		},
+		{
+			name:      "quote longer than content",
+			content:   "cat",
+			highlight: model.Highlight{StartOffset: 0, EndOffset: 7, Quote: "the cat"},
+			found:     false, start: 0, end: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.highlight
+			found := anchorHighlight([]rune(tt.content), &h)
+			if found != tt.found || h.StartOffset != tt.start || h.EndOffset != tt.end {
+				t.Fatalf("got %v [%d, %d), want %v [%d, %d)", found, h.StartOffset, h.EndOffset, tt.found, tt.start, tt.end)
+			}
+			if !found {
+				return
+			}
+
+			runes := []rune(tt.content)
+			if quote := string(runes[h.StartOffset:h.EndOffset]); quote != h.Quote {
+				t.Errorf("offsets select %q, want %q", quote, h.Quote)
+			}
+			if prefix := string(runes[max(0, h.StartOffset-highlightContextLength):h.StartOffset]); h.Prefix != prefix {
+				t.Errorf("prefix = %q, want %q", h.Prefix, prefix)
+			}
+			if suffix := string(runes[h.EndOffset:min(len(runes), h.EndOffset+highlightContextLength)]); h.Suffix != suffix {
+				t.Errorf("suffix = %q, want %q", h.Suffix, suffix)
+			}
+		})
+	}
+}
+
+func TestReanchorHighlights(t *testing.T) {
+	openTestDB(t)
+	userID := createTestUser(t, "reader")
+
+	article, err := SaveArticle(model.Article{
+		UserID:  userID,
+		URL:     "https://example.com/post",
+		Title:   "Post",
+		Content: "First paragraph. The key sentence. Last paragraph.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := CreateHighlight(model.Highlight{ArticleID: article.ID, Quote: "The key sentence.", Color: "yellow"}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err := CreateHighlight(model.Highlight{ArticleID: article.ID, Quote: "Last paragraph.", Color: "green"}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := "A new introduction. First paragraph, edited. The key sentence. 完。"
+	if err := RefreshArticle(article.ID, model.Article{Title: "Post", Content: refreshed}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := make(map[int]model.Highlight)
+	rows, err := DB.Query("SELECT id, start_offset, end_offset, prefix FROM highlights WHERE article_id = ?", article.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h model.Highlight
+		if err := rows.Scan(&h.ID, &h.StartOffset, &h.EndOffset, &h.Prefix); err != nil {
+			t.Fatal(err)
+		}
+		stored[h.ID] = h
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	runes := []rune(refreshed)
+	got := stored[kept.ID]
+	if quote := string(runes[got.StartOffset:got.EndOffset]); quote != kept.Quote {
+		t.Errorf("re-anchored highlight selects %q, want %q", quote, kept.Quote)
+	}
+	if prefix := string(runes[max(0, got.StartOffset-highlightContextLength):got.StartOffset]); got.Prefix != prefix {
+		t.Errorf("re-anchored prefix = %q, want %q", got.Prefix, prefix)
+	}
+
+	if got := stored[lost.ID]; got.StartOffset != lost.StartOffset || got.EndOffset != lost.EndOffset {
+		t.Errorf("highlight whose quote is gone moved to [%d, %d), want [%d, %d)",
+			got.StartOffset, got.EndOffset, lost.StartOffset, lost.EndOffset)
+	}
+}
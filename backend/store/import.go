@@ -0,0 +1,499 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"read-it-later/backend/extractor"
+	"read-it-later/backend/model"
+	"time"
+)
+
+// ErrArticleExists is returned when importing an article whose URL the user
+// has already saved.
+var ErrArticleExists = errors.New("article already saved")
+
+// importColumns lists the columns read by scanImport. The item counts are
+// computed from the import's items.
+const importColumns = `i.id, i.user_id, i.format, i.filename, i.status, i.created_at, i.finished_at,
+	(SELECT COUNT(*) FROM import_items WHERE import_id = i.id),
+	(SELECT COUNT(*) FROM import_items WHERE import_id = i.id AND status = 'pending'),
+	(SELECT COUNT(*) FROM import_items WHERE import_id = i.id AND status = 'imported'),
+	(SELECT COUNT(*) FROM import_items WHERE import_id = i.id AND status = 'skipped'),
+	(SELECT COUNT(*) FROM import_items WHERE import_id = i.id AND status = 'failed')`
+
+// scanImport scans a row selected with importColumns.
+func scanImport(row rowScanner) (model.Import, error) {
+	var imp model.Import
+	var finishedAt sql.NullTime
+	err := row.Scan(&imp.ID, &imp.UserID, &imp.Format, &imp.Filename, &imp.Status, &imp.CreatedAt, &finishedAt,
+		&imp.Total, &imp.Pending, &imp.Imported, &imp.Skipped, &imp.Failed)
+	if err != nil {
+		return model.Import{}, err
+	}
+	imp.FinishedAt = nullTimePtr(finishedAt)
+	return imp, nil
+}
+
+// CreateImport saves an import whose export is still being read. Its items
+// are added with AddImportItems, and StartImport marks it ready to run.
+func CreateImport(imp model.Import) (model.Import, error) {
+	res, err := DB.Exec("INSERT INTO imports(user_id, format, filename, status) VALUES(?, ?, ?, ?)",
+		imp.UserID, imp.Format, imp.Filename, model.ImportReading)
+	if err != nil {
+		return model.Import{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.Import{}, err
+	}
+
+	return GetImportByID(int(id), imp.UserID)
+}
+
+// AddImportItems saves a batch of pending items of an import being read.
+func AddImportItems(importID int, items []model.ImportItem) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO import_items(import_id, url, title, tags, saved_at, is_read, is_archived, is_favorite, status, data)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		tags, err := json.Marshal(item.Tags)
+		if err != nil {
+			return err
+		}
+		var data interface{}
+		if item.Exported != nil {
+			exported, err := json.Marshal(item.Exported)
+			if err != nil {
+				return err
+			}
+			data = string(exported)
+		}
+		_, err = stmt.Exec(importID, item.URL, item.Title, string(tags), importTimestamp(item.SavedAt),
+			item.IsRead, item.IsArchived, item.IsFavorite, model.ImportItemPending, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// StartImport marks an import whose export has been read as running.
+func StartImport(id int) error {
+	_, err := DB.Exec("UPDATE imports SET status = ? WHERE id = ? AND status = ?", model.ImportRunning, id, model.ImportReading)
+	return err
+}
+
+// DeleteImport deletes an import with its items, such as one whose export
+// could not be read.
+func DeleteImport(id int) error {
+	_, err := DB.Exec("DELETE FROM imports WHERE id = ?", id)
+	return err
+}
+
+// DeleteReadingImports deletes the imports whose export was still being read
+// when the server stopped. The rest of their file is gone, so they cannot be
+// resumed.
+func DeleteReadingImports() (int, error) {
+	res, err := DB.Exec("DELETE FROM imports WHERE status = ?", model.ImportReading)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// GetImportByID retrieves one of the user's imports.
+func GetImportByID(id int, userID int) (model.Import, error) {
+	return scanImport(DB.QueryRow("SELECT "+importColumns+" FROM imports i WHERE i.id = ? AND i.user_id = ?", id, userID))
+}
+
+// GetImportsForUser retrieves the user's imports, newest first.
+func GetImportsForUser(userID int) ([]model.Import, error) {
+	rows, err := DB.Query("SELECT "+importColumns+" FROM imports i WHERE i.user_id = ? ORDER BY i.id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := []model.Import{}
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, imp)
+	}
+	return imports, rows.Err()
+}
+
+// GetRunningImports retrieves the imports of all users that have not
+// finished, such as ones interrupted by a restart.
+func GetRunningImports() ([]model.Import, error) {
+	rows, err := DB.Query("SELECT "+importColumns+" FROM imports i WHERE i.status = ? ORDER BY i.id", model.ImportRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var imports []model.Import
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, imp)
+	}
+	return imports, rows.Err()
+}
+
+// FinishImport marks an import as done.
+func FinishImport(id int) error {
+	_, err := DB.Exec("UPDATE imports SET status = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?", model.ImportDone, id)
+	return err
+}
+
+// importItemColumns lists the columns read by scanImportItem.
+const importItemColumns = `ii.id, ii.import_id, ii.url, ii.title, ii.tags, ii.saved_at, ii.is_read, ii.is_archived,
+	ii.is_favorite, ii.status, ii.error, ii.article_id, COALESCE(a.status, '')`
+
+// scanImportItem scans a row selected with importItemColumns, followed by any
+// extra columns into extra.
+func scanImportItem(row rowScanner, extra ...interface{}) (model.ImportItem, error) {
+	var item model.ImportItem
+	var tags string
+	var savedAt sql.NullTime
+	var articleID sql.NullInt64
+	dest := []interface{}{&item.ID, &item.ImportID, &item.URL, &item.Title, &tags, &savedAt, &item.IsRead, &item.IsArchived,
+		&item.IsFavorite, &item.Status, &item.Error, &articleID, &item.ArticleStatus}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return model.ImportItem{}, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &item.Tags); err != nil {
+		return model.ImportItem{}, err
+	}
+	item.SavedAt = nullTimePtr(savedAt)
+	if articleID.Valid {
+		id := int(articleID.Int64)
+		item.ArticleID = &id
+	}
+	return item, nil
+}
+
+// GetImportItems retrieves the items of one of the user's imports in file
+// order, optionally only those with the given status.
+func GetImportItems(importID int, userID int, status string) ([]model.ImportItem, error) {
+	rows, err := DB.Query(`
+		SELECT `+importItemColumns+`
+		FROM import_items ii
+		JOIN imports i ON i.id = ii.import_id
+		LEFT JOIN articles a ON a.id = ii.article_id
+		WHERE ii.import_id = ? AND i.user_id = ? AND (? = '' OR ii.status = ?)
+		ORDER BY ii.id`, importID, userID, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.ImportItem{}
+	for rows.Next() {
+		item, err := scanImportItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetPendingImportItems retrieves up to limit items of an import that have
+// not been imported yet, in file order, with the exported articles of Read It
+// Later exports.
+func GetPendingImportItems(importID int, limit int) ([]model.ImportItem, error) {
+	rows, err := DB.Query(`
+		SELECT `+importItemColumns+`, ii.data
+		FROM import_items ii
+		LEFT JOIN articles a ON a.id = ii.article_id
+		WHERE ii.import_id = ? AND ii.status = ?
+		ORDER BY ii.id
+		LIMIT ?`, importID, model.ImportItemPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.ImportItem
+	for rows.Next() {
+		var data sql.NullString
+		item, err := scanImportItem(rows, &data)
+		if err != nil {
+			return nil, err
+		}
+		if data.Valid {
+			item.Exported = &model.ExportedArticle{}
+			if err := json.Unmarshal([]byte(data.String), item.Exported); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// CompleteImportItem records the outcome of importing an item. articleID is
+// the imported article, or the existing one for skipped items, or 0.
+func CompleteImportItem(id int, status string, articleID int, errMsg string) error {
+	var article interface{}
+	if articleID != 0 {
+		article = articleID
+	}
+	_, err := DB.Exec("UPDATE import_items SET status = ?, article_id = ?, error = ? WHERE id = ?", status, article, errMsg, id)
+	return err
+}
+
+// GetArticleIDByURL returns the ID of the user's article with the given URL,
+// or sql.ErrNoRows if the user has not saved it.
+func GetArticleIDByURL(userID int, url string) (int, error) {
+	var id int
+	err := DB.QueryRow("SELECT id FROM articles WHERE user_id = ? AND url = ?", userID, url).Scan(&id)
+	return id, err
+}
+
+// ImportArticle saves an imported article in the pending state with its tags
+// and reading state, together with a queued job to extract its content that
+// does not run before runAt (right away if zero). The article keeps its
+// CreatedAt if set. If the user already saved the URL, it returns the ID of
+// the existing article and ErrArticleExists.
+func ImportArticle(article model.Article, tags []string, runAt time.Time) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var existingID int
+	err = tx.QueryRow("SELECT id FROM articles WHERE user_id = ? AND url = ?", article.UserID, article.URL).Scan(&existingID)
+	if err == nil {
+		return existingID, ErrArticleExists
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	var createdAt interface{}
+	if !article.CreatedAt.IsZero() {
+		createdAt = FormatTimestamp(article.CreatedAt)
+	}
+	res, err := tx.Exec(`
+		INSERT INTO articles(user_id, url, title, domain, status, created_at,
+			is_read, read_at, is_archived, archived_at, is_favorite, favorited_at)
+		VALUES(?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP),
+			?, CASE WHEN ? THEN CURRENT_TIMESTAMP END,
+			?, CASE WHEN ? THEN CURRENT_TIMESTAMP END,
+			?, CASE WHEN ? THEN CURRENT_TIMESTAMP END)`,
+		article.UserID, article.URL, article.Title, articleDomain(article.URL), model.ArticlePending, createdAt,
+		article.IsRead, article.IsRead, article.IsArchived, article.IsArchived, article.IsFavorite, article.IsFavorite)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	articleID := int(id)
+
+	if _, err := insertJobAt(tx, article.UserID, articleID, model.JobKindExtract, runAt); err != nil {
+		return 0, err
+	}
+
+	if err := tagImportedArticle(tx, article.UserID, articleID, tags); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := indexArticle(articleID); err != nil {
+		log.Printf("Error indexing article %d: %v", articleID, err)
+	}
+
+	return articleID, nil
+}
+
+// RestoreArticle saves an article of a Read It Later export as it was
+// exported, with its content, timestamps, tags, reading progress and
+// highlights. Articles whose extraction had not succeeded are saved as pending
+// with a job to extract them that does not run before runAt (right away if
+// zero). If the user already saved the URL, it returns the ID of the existing
+// article and ErrArticleExists.
+//
+// Exports may come from someone else, so the content HTML is sanitized again
+// and the text and Markdown are derived from it. The reading progress is
+// limited to the text, and highlights are anchored to it: those whose quote is
+// not found are dropped, except for pending articles, which keep them to be
+// anchored once extracted.
+func RestoreArticle(userID int, exported model.ExportedArticle, tags []string, runAt time.Time) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var existingID int
+	err = tx.QueryRow("SELECT id FROM articles WHERE user_id = ? AND url = ?", userID, exported.URL).Scan(&existingID)
+	if err == nil {
+		return existingID, ErrArticleExists
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	status := exported.Status
+	if status != model.ArticleReady {
+		status = model.ArticlePending
+	}
+	domain := exported.Domain
+	if domain == "" {
+		domain = articleDomain(exported.URL)
+	}
+	contentHTML := extractor.SanitizeHTML(exported.ContentHTML, nil)
+	content, markdown := exported.Content, exported.Content
+	if contentHTML != "" {
+		content = extractor.HTMLToText(contentHTML)
+		markdown = extractor.HTMLToMarkdown(contentHTML)
+	}
+	contentRunes := []rune(content)
+	res, err := tx.Exec(`
+		INSERT INTO articles(user_id, url, title, content, content_html, markdown, excerpt, image_url, domain,
+			reading_time, status, created_at, refreshed_at,
+			is_read, read_at, is_archived, archived_at, is_favorite, favorited_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?, ?)`,
+		userID, exported.URL, exported.Title, content, contentHTML, markdown,
+		exported.Excerpt, exported.ImageURL, domain, exported.ReadingTime, status, restoredTimestamp(exported.CreatedAt),
+		importTimestamp(exported.RefreshedAt), exported.IsRead, importTimestamp(exported.ReadAt),
+		exported.IsArchived, importTimestamp(exported.ArchivedAt), exported.IsFavorite, importTimestamp(exported.FavoritedAt))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	articleID := int(id)
+
+	if status == model.ArticlePending {
+		if _, err := insertJobAt(tx, userID, articleID, model.JobKindExtract, runAt); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tagImportedArticle(tx, userID, articleID, tags); err != nil {
+		return 0, err
+	}
+
+	if progress := exported.Progress; progress != nil {
+		percentage := min(max(progress.Percentage, 0), 100)
+		offset := min(max(progress.Offset, 0), len(contentRunes))
+		_, err := tx.Exec(`
+			INSERT INTO reading_progress(article_id, user_id, percentage, char_offset, updated_at)
+			VALUES(?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))`,
+			articleID, userID, percentage, offset, restoredTimestamp(progress.UpdatedAt))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, exportedHighlight := range exported.Highlights {
+		h := model.Highlight{
+			StartOffset: exportedHighlight.StartOffset,
+			EndOffset:   exportedHighlight.EndOffset,
+			Quote:       exportedHighlight.Quote,
+			Prefix:      exportedHighlight.Prefix,
+			Suffix:      exportedHighlight.Suffix,
+		}
+		if !anchorHighlight(contentRunes, &h) {
+			if status == model.ArticleReady || h.Quote == "" {
+				continue
+			}
+			// Pending articles have no text yet; the quote and context anchor
+			// the highlight once it is extracted
+			h.StartOffset, h.EndOffset = 0, 0
+		}
+
+		color := exportedHighlight.Color
+		if !IsValidHighlightColor(color) {
+			color = highlightColors[0]
+		}
+		_, err := tx.Exec(`
+			INSERT INTO highlights(article_id, user_id, start_offset, end_offset, quote, prefix, suffix, note, color,
+				created_at, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))`,
+			articleID, userID, h.StartOffset, h.EndOffset, h.Quote, h.Prefix, h.Suffix, exportedHighlight.Note, color,
+			restoredTimestamp(exportedHighlight.CreatedAt), restoredTimestamp(exportedHighlight.UpdatedAt))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := indexArticle(articleID); err != nil {
+		log.Printf("Error indexing article %d: %v", articleID, err)
+	}
+
+	return articleID, nil
+}
+
+// tagImportedArticle adds tags to an imported article, creating the ones the
+// user does not have yet.
+func tagImportedArticle(tx *sql.Tx, userID int, articleID int, tags []string) error {
+	for _, name := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags(user_id, name) VALUES(?, ?)", userID, name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO article_tags(article_id, tag_id)
+			SELECT ?, id FROM tags WHERE user_id = ? AND name = ?`, articleID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importTimestamp converts an optional time for the import_items table.
+func importTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return FormatTimestamp(*t)
+}
+
+// restoredTimestamp converts a time of an exported article, or returns nil
+// for the zero time so the column falls back to the current time.
+func restoredTimestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return FormatTimestamp(t)
+}
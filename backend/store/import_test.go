@@ -0,0 +1,158 @@
+package store
+
+import (
+	"encoding/json"
+	"read-it-later/backend/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestoreArticleRoundTrip(t *testing.T) {
+	openTestDB(t)
+	owner := createTestUser(t, "owner")
+	restorer := createTestUser(t, "restorer")
+
+	const content = "Go is fun. Go is fast. 中文测试 Go."
+	article, err := SaveArticle(model.Article{
+		UserID:      owner,
+		URL:         "https://example.com/go",
+		Title:       "Go",
+		Content:     content,
+		ContentHTML: "<p>" + content + "</p>",
+		Status:      model.ArticleReady,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"go", "语言"} {
+		if _, err := AddTagToArticleByID(article.ID, tag, owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read, favorite := true, true
+	if _, err := UpdateArticleState(article.ID, owner, model.ArticleStateUpdate{IsRead: &read, IsFavorite: &favorite}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveReadingProgress(model.ReadingProgress{ArticleID: article.ID, Percentage: 42.5, Offset: 23}, owner); err != nil {
+		t.Fatal(err)
+	}
+	// The second "Go" is repeated, so restoring it depends on its context
+	highlight, err := CreateHighlight(model.Highlight{
+		ArticleID:   article.ID,
+		StartOffset: 11,
+		EndOffset:   13,
+		Note:        "speed",
+		Color:       "green",
+	}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Export the article the way the export handler does
+	var exported model.ExportedArticle
+	err = EachArticle(owner, ArticleFilter{}, func(a model.Article) error {
+		exported = model.ExportedArticle{
+			URL:         a.URL,
+			Title:       a.Title,
+			Content:     a.Content,
+			ContentHTML: a.ContentHTML,
+			Markdown:    a.Markdown,
+			Status:      a.Status,
+			CreatedAt:   a.CreatedAt,
+			IsRead:      a.IsRead,
+			ReadAt:      a.ReadAt,
+			IsArchived:  a.IsArchived,
+			ArchivedAt:  a.ArchivedAt,
+			IsFavorite:  a.IsFavorite,
+			FavoritedAt: a.FavoritedAt,
+		}
+		for _, tag := range a.Tags {
+			exported.Tags = append(exported.Tags, tag.Name)
+		}
+		if p := a.Progress; p != nil {
+			exported.Progress = &model.ExportedProgress{Percentage: p.Percentage, Offset: p.Offset, UpdatedAt: p.UpdatedAt}
+		}
+		highlights, err := GetHighlightsForArticle(a.ID, owner)
+		if err != nil {
+			return err
+		}
+		for _, h := range highlights {
+			exported.Highlights = append(exported.Highlights, model.ExportedHighlight{
+				StartOffset: h.StartOffset,
+				EndOffset:   h.EndOffset,
+				Quote:       h.Quote,
+				Prefix:      h.Prefix,
+				Suffix:      h.Suffix,
+				Note:        h.Note,
+				Color:       h.Color,
+				CreatedAt:   h.CreatedAt,
+				UpdatedAt:   h.UpdatedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded model.ExportedArticle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredID, err := RestoreArticle(restorer, decoded, decoded.Tags, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := GetArticleByID(restoredID, restorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Status != model.ArticleReady || restored.Content != content {
+		t.Errorf("restored status %q content %q, want %q %q", restored.Status, restored.Content, model.ArticleReady, content)
+	}
+	if !restored.IsRead || restored.IsArchived || !restored.IsFavorite {
+		t.Errorf("restored state read=%v archived=%v favorite=%v, want true false true",
+			restored.IsRead, restored.IsArchived, restored.IsFavorite)
+	}
+
+	tags, err := GetTagsForArticle(restoredID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tagNames []string
+	for _, tag := range tags {
+		tagNames = append(tagNames, tag.Name)
+	}
+	if !reflect.DeepEqual(tagNames, exported.Tags) {
+		t.Errorf("restored tags %q, want %q", tagNames, exported.Tags)
+	}
+
+	progress, err := GetReadingProgress(restoredID, restorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Percentage != 42.5 || progress.Offset != 23 {
+		t.Errorf("restored progress %v%% at %d, want 42.5%% at 23", progress.Percentage, progress.Offset)
+	}
+
+	highlights, err := GetHighlightsForArticle(restoredID, restorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(highlights) != 1 {
+		t.Fatalf("restored %d highlights, want 1", len(highlights))
+	}
+	got := highlights[0]
+	if got.StartOffset != highlight.StartOffset || got.EndOffset != highlight.EndOffset ||
+		got.Quote != highlight.Quote || got.Note != highlight.Note || got.Color != highlight.Color {
+		t.Errorf("restored highlight %+v, want %+v", got, highlight)
+	}
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"read-it-later/backend/model"
+	"time"
+)
+
+// ErrJobInProgress is returned when an article already has a queued or running job.
+var ErrJobInProgress = errors.New("article already has a job in progress")
+
+// jobColumns lists the columns read by scanJob.
+const jobColumns = "id, user_id, article_id, kind, status, attempts, max_attempts, last_error, run_at, created_at, updated_at"
+
+// scanJob scans a row selected with jobColumns.
+func scanJob(row rowScanner) (model.Job, error) {
+	var job model.Job
+	err := row.Scan(&job.ID, &job.UserID, &job.ArticleID, &job.Kind, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.LastError, &job.RunAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return model.Job{}, err
+	}
+	return job, nil
+}
+
+// insertJob queues a job for an article.
+func insertJob(db execer, userID int, articleID int, kind string) (int, error) {
+	return insertJobAt(db, userID, articleID, kind, time.Time{})
+}
+
+// insertJobAt queues a job for an article that does not run before runAt, or
+// right away if runAt is zero.
+func insertJobAt(db execer, userID int, articleID int, kind string, runAt time.Time) (int, error) {
+	var at interface{}
+	if !runAt.IsZero() {
+		at = FormatTimestamp(runAt)
+	}
+	res, err := db.Exec("INSERT INTO jobs(user_id, article_id, kind, run_at) VALUES(?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))",
+		userID, articleID, kind, at)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// SaveArticleForExtraction saves a placeholder article in the pending state
+// together with a queued job to extract its content.
+func SaveArticleForExtraction(article model.Article) (model.Article, model.Job, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+	defer tx.Rollback()
+
+	article.Status = model.ArticlePending
+	article, err = insertArticle(tx, article)
+	if err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+
+	jobID, err := insertJob(tx, article.UserID, article.ID, model.JobKindExtract)
+	if err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+
+	if err := indexArticle(article.ID); err != nil {
+		log.Printf("Error indexing article %d: %v", article.ID, err)
+	}
+
+	savedArticle, err := GetArticleByID(article.ID, article.UserID)
+	if err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+
+	job, err := GetJobByID(jobID, article.UserID)
+	if err != nil {
+		return model.Article{}, model.Job{}, err
+	}
+
+	return savedArticle, job, nil
+}
+
+// QueueArticleRefresh queues a job of the given kind to extract the content
+// of one of the user's articles again. It returns ErrJobInProgress if the
+// article is still waiting for another job.
+func QueueArticleRefresh(articleID int, userID int, kind string) (model.Job, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return model.Job{}, err
+	}
+	defer tx.Rollback()
+
+	// Check if article exists and belongs to the user
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM articles WHERE id = ? AND user_id = ?)", articleID, userID).Scan(&exists)
+	if err != nil {
+		return model.Job{}, err
+	}
+	if !exists {
+		return model.Job{}, sql.ErrNoRows
+	}
+
+	// Page captures run alongside and do not block a refresh
+	var busy bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM jobs WHERE article_id = ? AND status IN (?, ?) AND kind != ?)",
+		articleID, model.JobQueued, model.JobRunning, model.JobKindCapture).Scan(&busy)
+	if err != nil {
+		return model.Job{}, err
+	}
+	if busy {
+		return model.Job{}, ErrJobInProgress
+	}
+
+	jobID, err := insertJob(tx, userID, articleID, kind)
+	if err != nil {
+		return model.Job{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Job{}, err
+	}
+
+	return GetJobByID(jobID, userID)
+}
+
+// QueueJob queues a job for an article.
+func QueueJob(articleID int, userID int, kind string) (model.Job, error) {
+	jobID, err := insertJob(DB, userID, articleID, kind)
+	if err != nil {
+		return model.Job{}, err
+	}
+	return GetJobByID(jobID, userID)
+}
+
+// GetJobByID retrieves a job by its ID and user ID.
+func GetJobByID(id int, userID int) (model.Job, error) {
+	return scanJob(DB.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ? AND user_id = ?", id, userID))
+}
+
+// ClaimNextJob marks the oldest due queued job as running and returns it. It
+// returns sql.ErrNoRows if no job is due.
+func ClaimNextJob() (model.Job, error) {
+	return scanJob(DB.QueryRow(`
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_at <= CURRENT_TIMESTAMP
+			ORDER BY run_at, id
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, model.JobRunning, model.JobQueued))
+}
+
+// CompleteJob marks a job as done.
+func CompleteJob(id int) error {
+	_, err := DB.Exec("UPDATE jobs SET status = ?, last_error = '', updated_at = CURRENT_TIMESTAMP WHERE id = ?", model.JobDone, id)
+	return err
+}
+
+// RetryJob puts a failed job back in the queue to run again after delay.
+func RetryJob(id int, lastError string, delay time.Duration) error {
+	_, err := DB.Exec(`
+		UPDATE jobs
+		SET status = ?, last_error = ?, run_at = datetime('now', ?), updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		model.JobQueued, lastError, fmt.Sprintf("+%d seconds", int(delay.Seconds())), id)
+	return err
+}
+
+// FailJob marks a job as permanently failed.
+func FailJob(id int, lastError string) error {
+	_, err := DB.Exec("UPDATE jobs SET status = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", model.JobFailed, lastError, id)
+	return err
+}
+
+// RequeueInterruptedJobs puts jobs that were running when the server stopped
+// back in the queue and returns how many there were.
+func RequeueInterruptedJobs() (int64, error) {
+	result, err := DB.Exec("UPDATE jobs SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE status = ?", model.JobQueued, model.JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,405 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"read-it-later/backend/model"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageSize is the number of articles returned when no limit is given.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest number of articles returned in one page.
+	MaxPageSize = 200
+)
+
+// timestampFormat is the layout SQLite's CURRENT_TIMESTAMP stores, used for
+// every timestamp the store compares against.
+const timestampFormat = "2006-01-02 15:04:05"
+
+// relevanceExpr ranks full-text matches; title matches weigh the most,
+// followed by tags, the excerpt, the body and sub-words of Chinese words.
+const relevanceExpr = "bm25(articles_fts, 10.0, 3.0, 1.0, 5.0, 0.5)"
+
+// ErrInvalidCursor is returned when a pagination cursor is malformed or was
+// issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortColumns maps each sort option to the expression articles are ordered
+// by and the expression stored in the cursor to resume after a row.
+var sortColumns = map[string]struct {
+	orderBy string
+	key     string
+}{
+	"created_at":   {"a.created_at", "CAST(a.created_at AS TEXT)"},
+	"title":        {"a.title COLLATE NOCASE", "a.title"},
+	"reading_time": {"a.reading_time", "a.reading_time"},
+	"domain":       {"a.domain", "a.domain"},
+	"relevance":    {relevanceExpr, relevanceExpr},
+}
+
+// ArticleFilter narrows the articles returned by a list.
+type ArticleFilter struct {
+	// State is one of "unread", "read" or "archived"; empty means all articles.
+	State    string
+	Favorite *bool
+	// Tags lists tag names an article must all carry.
+	Tags []string
+	// TagQuery matches articles with any tag whose name contains it.
+	TagQuery string
+	Domain   string
+	// CreatedAfter is inclusive and CreatedBefore exclusive.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ListOptions controls the filtering, sorting and pagination of an article list.
+type ListOptions struct {
+	Filter ArticleFilter
+	// Sort is one of "created_at", "title", "reading_time", "domain" or,
+	// for searches, "relevance".
+	Sort string
+	// Order is "asc" or "desc". For relevance, "desc" lists the best matches first.
+	Order string
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first page.
+	Cursor string
+}
+
+// cursor identifies the last article of a page. It is encoded as opaque
+// base64 JSON so clients do not depend on its contents.
+type cursor struct {
+	Sort  string      `json:"s"`
+	Order string      `json:"o"`
+	Key   interface{} `json:"k"`
+	ID    int         `json:"id"`
+}
+
+// IsValidArticleState reports whether state can be used as ArticleFilter.State.
+func IsValidArticleState(state string) bool {
+	switch state {
+	case "", "all", "unread", "read", "archived":
+		return true
+	}
+	return false
+}
+
+// IsValidSort reports whether sort can be used as ListOptions.Sort. Sorting by
+// relevance is only possible for full-text searches.
+func IsValidSort(sort string, search bool) bool {
+	if sort == "relevance" {
+		return search
+	}
+	_, ok := sortColumns[sort]
+	return ok
+}
+
+// FormatTimestamp formats t the way SQLite's CURRENT_TIMESTAMP stores it.
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampFormat)
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// filterConditions translates a filter into SQL conditions on the articles
+// table aliased as "a", and their arguments.
+func filterConditions(userID int, filter ArticleFilter) ([]string, []interface{}) {
+	conditions := []string{"a.user_id = ?"}
+	args := []interface{}{userID}
+
+	switch filter.State {
+	case "unread":
+		// Unread articles that have not been archived form the reading queue
+		conditions = append(conditions, "a.is_read = 0", "a.is_archived = 0")
+	case "read":
+		conditions = append(conditions, "a.is_read = 1")
+	case "archived":
+		conditions = append(conditions, "a.is_archived = 1")
+	}
+
+	if filter.Favorite != nil {
+		conditions = append(conditions, "a.is_favorite = ?")
+		args = append(args, *filter.Favorite)
+	}
+
+	if len(filter.Tags) > 0 {
+		tags := make(map[string]bool)
+		placeholders := make([]string, 0, len(filter.Tags))
+		for _, tag := range filter.Tags {
+			if tags[tag] {
+				continue
+			}
+			tags[tag] = true
+			placeholders = append(placeholders, "?")
+			args = append(args, tag)
+		}
+		conditions = append(conditions, `a.id IN (
+			SELECT at.article_id
+			FROM article_tags at
+			JOIN tags t ON t.id = at.tag_id
+			WHERE t.name IN (`+strings.Join(placeholders, ", ")+`)
+			GROUP BY at.article_id
+			HAVING COUNT(DISTINCT t.id) = ?)`)
+		args = append(args, len(tags))
+	}
+
+	if filter.TagQuery != "" {
+		conditions = append(conditions, `a.id IN (
+			SELECT at.article_id
+			FROM article_tags at
+			JOIN tags t ON t.id = at.tag_id
+			WHERE t.name LIKE ?)`)
+		args = append(args, "%"+filter.TagQuery+"%")
+	}
+
+	if filter.Domain != "" {
+		// Include subdomains, so "zhihu.com" also matches "zhuanlan.zhihu.com"
+		conditions = append(conditions, "(a.domain = ? OR a.domain LIKE ?)")
+		domain := strings.TrimPrefix(strings.ToLower(filter.Domain), "www.")
+		args = append(args, domain, "%."+domain)
+	}
+
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, FormatTimestamp(*filter.CreatedAfter))
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "a.created_at < ?")
+		args = append(args, FormatTimestamp(*filter.CreatedBefore))
+	}
+
+	return conditions, args
+}
+
+// ListArticles retrieves one page of a user's articles.
+func ListArticles(userID int, opts ListOptions) (model.ArticlePage, error) {
+	return listArticles(userID, opts, "")
+}
+
+// SearchArticles retrieves one page of a ranked full-text search over the
+// title, excerpt, content and tags of a user's articles. Each result carries a
+// snippet of the content, or of the title when only the title matched, with
+// the matching terms wrapped in <mark> tags.
+func SearchArticles(query string, userID int, opts ListOptions) (model.ArticlePage, error) {
+	matchQuery := buildMatchQuery(query)
+	if matchQuery == "" {
+		return model.ArticlePage{Articles: []model.Article{}}, nil
+	}
+	return listArticles(userID, opts, matchQuery)
+}
+
+// listArticles retrieves one page of articles using keyset pagination on the
+// sort key and article ID. If matchQuery is not empty, only articles matching
+// it in the full-text index are listed.
+func listArticles(userID int, opts ListOptions, matchQuery string) (model.ArticlePage, error) {
+	if opts.Sort == "" {
+		opts.Sort = "created_at"
+	}
+	if opts.Order == "" {
+		opts.Order = "desc"
+	}
+	if opts.Limit <= 0 || opts.Limit > MaxPageSize {
+		opts.Limit = DefaultPageSize
+	}
+
+	sortColumn, ok := sortColumns[opts.Sort]
+	if !ok || (opts.Sort == "relevance" && matchQuery == "") {
+		return model.ArticlePage{}, fmt.Errorf("unsupported sort %q", opts.Sort)
+	}
+
+	// Lower bm25 scores are better, so the best matches come first in ascending order
+	ascending := opts.Order == "asc"
+	if opts.Sort == "relevance" {
+		ascending = !ascending
+	}
+	direction, comparison := "DESC", "<"
+	if ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	conditions, args := filterConditions(userID, opts.Filter)
+
+	from := `
+		FROM articles a
+		LEFT JOIN reading_progress p ON p.article_id = a.id`
+	snippets := "'', ''"
+	if matchQuery != "" {
+		from += `
+		JOIN articles_fts ON articles_fts.rowid = a.id`
+		conditions = append(conditions, "articles_fts MATCH ?", "articles_fts.user_id = ?")
+		args = append(args, matchQuery, userID)
+		snippets = `snippet(articles_fts, 2, '<mark>', '</mark>', '…', 24),
+			snippet(articles_fts, 0, '<mark>', '</mark>', '…', 24)`
+	}
+
+	page := model.ArticlePage{Articles: []model.Article{}}
+
+	err := DB.QueryRow("SELECT COUNT(*) "+from+" WHERE "+strings.Join(conditions, " AND "), args...).Scan(&page.Total)
+	if err != nil {
+		return model.ArticlePage{}, err
+	}
+
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return model.ArticlePage{}, err
+		}
+		if after.Sort != opts.Sort || after.Order != opts.Order {
+			return model.ArticlePage{}, ErrInvalidCursor
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s %s ? OR (%s = ? AND a.id %s ?))",
+			sortColumn.orderBy, comparison, sortColumn.orderBy, comparison))
+		args = append(args, after.Key, after.Key, after.ID)
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	args = append(args, opts.Limit+1)
+	rows, err := DB.Query(`
+		SELECT `+articleColumns+`,
+			`+sortColumn.key+`,
+			`+snippets+from+`
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+sortColumn.orderBy+` `+direction+`, a.id `+direction+`
+		LIMIT ?`, args...)
+	if err != nil {
+		return model.ArticlePage{}, err
+	}
+	defer rows.Close()
+
+	var lastKey interface{}
+	for rows.Next() {
+		var article model.Article
+		var sortKey interface{}
+		var contentSnippet, titleSnippet string
+		if err := scanArticle(rows, &article, &sortKey, &contentSnippet, &titleSnippet); err != nil {
+			return model.ArticlePage{}, err
+		}
+
+		if len(page.Articles) == opts.Limit {
+			last := page.Articles[len(page.Articles)-1]
+			page.NextCursor = encodeCursor(cursor{Sort: opts.Sort, Order: opts.Order, Key: lastKey, ID: last.ID})
+			break
+		}
+
+		article.Snippet = cleanSnippet(contentSnippet)
+		if !strings.Contains(contentSnippet, "<mark>") && strings.Contains(titleSnippet, "<mark>") {
+			article.Snippet = cleanSnippet(titleSnippet)
+		}
+
+		page.Articles = append(page.Articles, article)
+		lastKey = sortKey
+	}
+
+	if err := rows.Err(); err != nil {
+		return model.ArticlePage{}, err
+	}
+
+	if err := attachTags(page.Articles); err != nil {
+		// Log the error but don't fail the entire request
+		log.Printf("Error getting tags for articles: %v", err)
+	}
+
+	return page, nil
+}
+
+// articleColumns lists the columns read by scanArticle. Queries using it must
+// alias articles as "a" and LEFT JOIN reading_progress as "p".
+const articleColumns = `a.id, a.user_id, a.url, a.title, COALESCE(a.excerpt, ''), COALESCE(a.image_url, ''),
+			a.domain, a.reading_time, a.status, a.created_at, a.refreshed_at,
+			a.is_read, a.read_at, a.is_archived, a.archived_at, a.is_favorite, a.favorited_at,
+			p.percentage, p.char_offset, p.updated_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanArticle scans a row selected with articleColumns into article, followed
+// by any extra columns into extra.
+func scanArticle(row rowScanner, article *model.Article, extra ...interface{}) error {
+	var refreshedAt, readAt, archivedAt, favoritedAt sql.NullTime
+	var progress nullProgress
+
+	dest := []interface{}{
+		&article.ID, &article.UserID, &article.URL, &article.Title, &article.Excerpt, &article.ImageURL,
+		&article.Domain, &article.ReadingTime, &article.Status, &article.CreatedAt, &refreshedAt,
+		&article.IsRead, &readAt, &article.IsArchived, &archivedAt, &article.IsFavorite, &favoritedAt,
+		&progress.percentage, &progress.offset, &progress.updatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	article.RefreshedAt = nullTimePtr(refreshedAt)
+	article.ReadAt = nullTimePtr(readAt)
+	article.ArchivedAt = nullTimePtr(archivedAt)
+	article.FavoritedAt = nullTimePtr(favoritedAt)
+	article.Progress = progress.toModel(article.ID)
+	return nil
+}
+
+// attachTags loads the tags of all given articles with a single query.
+func attachTags(articles []model.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(articles))
+	args := make([]interface{}, len(articles))
+	for i, article := range articles {
+		placeholders[i] = "?"
+		args[i] = article.ID
+	}
+
+	rows, err := DB.Query(`
+		SELECT at.article_id, t.id, t.name
+		FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY t.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tagsByArticle := make(map[int][]model.Tag)
+	for rows.Next() {
+		var articleID int
+		var tag model.Tag
+		if err := rows.Scan(&articleID, &tag.ID, &tag.Name); err != nil {
+			return err
+		}
+		tagsByArticle[articleID] = append(tagsByArticle[articleID], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range articles {
+		articles[i].Tags = tagsByArticle[articles[i].ID]
+	}
+	return nil
+}
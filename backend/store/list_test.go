@@ -0,0 +1,119 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"read-it-later/backend/model"
+	"testing"
+)
+
+const (
+	// benchArticles and benchTags size the seeded database.
+	benchArticles = 2000
+	benchTags     = 50
+	// benchPageSize is the number of articles listed per call.
+	benchPageSize = 100
+)
+
+// seedBenchDB opens a temporary database holding one user with benchArticles
+// articles, each carrying three of benchTags tags. It returns the user's ID.
+func seedBenchDB(b *testing.B) int {
+	b.Helper()
+	InitDB(filepath.Join(b.TempDir(), "bench.db"))
+	b.Cleanup(func() { DB.Close() })
+
+	userID, err := CreateUser(model.User{Username: "bench", Email: "bench@example.com", Password: "x"})
+	if err != nil {
+		b.Fatalf("creating user: %v", err)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	tagIDs := make([]int64, benchTags)
+	for i := range tagIDs {
+		res, err := tx.Exec("INSERT INTO tags(user_id, name) VALUES(?, ?)", userID, fmt.Sprintf("tag-%d", i))
+		if err != nil {
+			b.Fatalf("seeding tags: %v", err)
+		}
+		if tagIDs[i], err = res.LastInsertId(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for i := 0; i < benchArticles; i++ {
+		res, err := tx.Exec("INSERT INTO articles(user_id, url, title, content, domain) VALUES(?, ?, ?, ?, ?)",
+			userID, fmt.Sprintf("https://example.com/%d", i), fmt.Sprintf("Article %d", i), "content", "example.com")
+		if err != nil {
+			b.Fatalf("seeding articles: %v", err)
+		}
+		articleID, err := res.LastInsertId()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			if _, err := tx.Exec("INSERT INTO article_tags(article_id, tag_id) VALUES(?, ?)",
+				articleID, tagIDs[(i+j)%benchTags]); err != nil {
+				b.Fatalf("seeding article tags: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return userID
+}
+
+// BenchmarkListArticles lists a page of articles, loading the tags of the
+// whole page in one query.
+func BenchmarkListArticles(b *testing.B) {
+	userID := seedBenchDB(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		page, err := ListArticles(userID, ListOptions{Limit: benchPageSize})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(page.Articles) != benchPageSize || len(page.Articles[0].Tags) != 3 {
+			b.Fatalf("unexpected page: %d articles", len(page.Articles))
+		}
+	}
+}
+
+// BenchmarkListArticlesTags compares loading the tags of a page of articles
+// in one query with the one query per article the store used to run.
+func BenchmarkListArticlesTags(b *testing.B) {
+	userID := seedBenchDB(b)
+	page, err := ListArticles(userID, ListOptions{Limit: benchPageSize})
+	if err != nil {
+		b.Fatal(err)
+	}
+	articles := page.Articles
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := attachTags(articles); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(1, "queries/op")
+	})
+
+	b.Run("per-article", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range articles {
+				tags, err := GetTagsForArticle(articles[j].ID)
+				if err != nil {
+					b.Fatal(err)
+				}
+				articles[j].Tags = tags
+			}
+		}
+		b.ReportMetric(float64(len(articles)), "queries/op")
+	})
+}
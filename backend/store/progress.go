@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"read-it-later/backend/model"
+)
+
+// nullProgress holds the reading_progress columns of a LEFT JOIN, which are
+// all NULL when the article has never been opened.
+type nullProgress struct {
+	percentage sql.NullFloat64
+	offset     sql.NullInt64
+	updatedAt  sql.NullTime
+}
+
+// toModel converts the joined columns into a ReadingProgress, or nil if the
+// article has no progress record.
+func (p nullProgress) toModel(articleID int) *model.ReadingProgress {
+	if !p.percentage.Valid {
+		return nil
+	}
+	return &model.ReadingProgress{
+		ArticleID:  articleID,
+		Percentage: p.percentage.Float64,
+		Offset:     int(p.offset.Int64),
+		UpdatedAt:  p.updatedAt.Time,
+	}
+}
+
+// GetReadingProgress retrieves the reading progress of an article for a specific user.
+func GetReadingProgress(articleID int, userID int) (model.ReadingProgress, error) {
+	progress := model.ReadingProgress{ArticleID: articleID}
+	err := DB.QueryRow("SELECT percentage, char_offset, updated_at FROM reading_progress WHERE article_id = ? AND user_id = ?", articleID, userID).
+		Scan(&progress.Percentage, &progress.Offset, &progress.UpdatedAt)
+	if err != nil {
+		return model.ReadingProgress{}, err
+	}
+
+	return progress, nil
+}
+
+// SaveReadingProgress creates or replaces the reading progress of an article
+// for a specific user and returns the stored record.
+func SaveReadingProgress(progress model.ReadingProgress, userID int) (model.ReadingProgress, error) {
+	// Check if article exists and belongs to the user
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM articles WHERE id = ? AND user_id = ?)", progress.ArticleID, userID).Scan(&exists)
+	if err != nil {
+		return model.ReadingProgress{}, err
+	}
+	if !exists {
+		return model.ReadingProgress{}, sql.ErrNoRows
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO reading_progress(article_id, user_id, percentage, char_offset, updated_at)
+		VALUES(?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(article_id) DO UPDATE SET
+			percentage = excluded.percentage,
+			char_offset = excluded.char_offset,
+			updated_at = excluded.updated_at`,
+		progress.ArticleID, userID, progress.Percentage, progress.Offset)
+	if err != nil {
+		return model.ReadingProgress{}, err
+	}
+
+	return GetReadingProgress(progress.ArticleID, userID)
+}
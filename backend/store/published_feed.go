@@ -0,0 +1,171 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"read-it-later/backend/model"
+	"strings"
+)
+
+// publishedFeedColumns lists the columns read by scanPublishedFeed.
+const publishedFeedColumns = `pf.id, pf.user_id, pf.title, pf.tag_id, COALESCE(t.name, ''), pf.state, pf.token,
+	pf.created_at, pf.token_rotated_at`
+
+// publishedFeedFrom joins published feeds with the name of their tag.
+const publishedFeedFrom = ` FROM published_feeds pf LEFT JOIN tags t ON t.id = pf.tag_id`
+
+// scanPublishedFeed scans a row selected with publishedFeedColumns.
+func scanPublishedFeed(row rowScanner) (model.PublishedFeed, error) {
+	var feed model.PublishedFeed
+	var tagID sql.NullInt64
+	var rotatedAt sql.NullTime
+	err := row.Scan(&feed.ID, &feed.UserID, &feed.Title, &tagID, &feed.TagName, &feed.State, &feed.Token,
+		&feed.CreatedAt, &rotatedAt)
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+
+	if tagID.Valid {
+		id := int(tagID.Int64)
+		feed.TagID = &id
+	}
+	feed.TokenRotatedAt = nullTimePtr(rotatedAt)
+	return feed, nil
+}
+
+// newURLToken returns a random token for URLs read by clients that cannot
+// send the Authorization header, such as feed and e-book readers.
+func newURLToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreatePublishedFeed publishes a feed of the user's articles with a new
+// token.
+func CreatePublishedFeed(feed model.PublishedFeed) (model.PublishedFeed, error) {
+	token, err := newURLToken()
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+
+	res, err := DB.Exec("INSERT INTO published_feeds(user_id, title, tag_id, state, token) VALUES(?, ?, ?, ?, ?)",
+		feed.UserID, feed.Title, feed.TagID, feed.State, token)
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+
+	return GetPublishedFeedByID(int(id), feed.UserID)
+}
+
+// GetPublishedFeedByID retrieves one of the user's published feeds.
+func GetPublishedFeedByID(id int, userID int) (model.PublishedFeed, error) {
+	return scanPublishedFeed(DB.QueryRow("SELECT "+publishedFeedColumns+publishedFeedFrom+
+		" WHERE pf.id = ? AND pf.user_id = ?", id, userID))
+}
+
+// GetPublishedFeedByToken retrieves the published feed with the given token.
+func GetPublishedFeedByToken(token string) (model.PublishedFeed, error) {
+	return scanPublishedFeed(DB.QueryRow("SELECT "+publishedFeedColumns+publishedFeedFrom+" WHERE pf.token = ?", token))
+}
+
+// GetPublishedFeedsForUser retrieves the user's published feeds, oldest first.
+func GetPublishedFeedsForUser(userID int) ([]model.PublishedFeed, error) {
+	rows, err := DB.Query("SELECT "+publishedFeedColumns+publishedFeedFrom+" WHERE pf.user_id = ? ORDER BY pf.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []model.PublishedFeed{}
+	for rows.Next() {
+		feed, err := scanPublishedFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// RotatePublishedFeedToken replaces the token of one of the user's published
+// feeds, so the old URL stops working.
+func RotatePublishedFeedToken(id int, userID int) (model.PublishedFeed, error) {
+	token, err := newURLToken()
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+
+	res, err := DB.Exec("UPDATE published_feeds SET token = ?, token_rotated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?",
+		token, id, userID)
+	if err != nil {
+		return model.PublishedFeed{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return model.PublishedFeed{}, err
+	} else if n == 0 {
+		return model.PublishedFeed{}, sql.ErrNoRows
+	}
+
+	return GetPublishedFeedByID(id, userID)
+}
+
+// DeletePublishedFeed stops publishing one of the user's feeds.
+func DeletePublishedFeed(id int, userID int) error {
+	res, err := DB.Exec("DELETE FROM published_feeds WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetLatestArticles retrieves up to limit of the user's articles matching
+// filter whose content has been extracted, most recently saved first, with
+// their content and tags.
+func GetLatestArticles(userID int, filter ArticleFilter, limit int) ([]model.Article, error) {
+	conditions, args := filterConditions(userID, filter)
+	conditions = append(conditions, "a.status = ?")
+	args = append(args, model.ArticleReady, limit)
+
+	rows, err := DB.Query(`
+		SELECT `+articleColumns+`, COALESCE(a.content, ''), a.content_html
+		FROM articles a
+		LEFT JOIN reading_progress p ON p.article_id = a.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []model.Article{}
+	for rows.Next() {
+		var article model.Article
+		if err := scanArticle(rows, &article, &article.Content, &article.ContentHTML); err != nil {
+			return nil, err
+		}
+		articles = append(articles, article)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachTags(articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
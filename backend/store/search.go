@@ -0,0 +1,145 @@
+package store
+
+import (
+	"database/sql"
+	"log"
+	"read-it-later/backend/segment"
+	"strings"
+	"unicode"
+)
+
+// wordSeparator is inserted between Chinese words before indexing. The
+// unicode61 tokenizer treats it as a separator, and since it is invisible it
+// only has to be stripped from snippets rather than the other way round.
+const wordSeparator = "\u200b"
+
+// segmentForIndex separates the Chinese words in text so the tokenizer
+// indexes them individually, and collects the shorter words contained in long
+// Chinese words so they can be indexed as well.
+func segmentForIndex(text string) (string, []string) {
+	words := segment.Cut(text)
+	var subWords []string
+	for _, word := range words {
+		subWords = append(subWords, segment.SubWords(word)...)
+	}
+	return strings.Join(words, wordSeparator), subWords
+}
+
+// cleanSnippet removes word separators from a snippet and merges the marks of
+// adjacent highlighted words.
+func cleanSnippet(snippet string) string {
+	snippet = strings.ReplaceAll(snippet, wordSeparator, "")
+	return strings.ReplaceAll(snippet, "</mark><mark>", "")
+}
+
+// indexArticle rebuilds the full-text search entry of an article from its
+// current title, excerpt, content and tag names.
+func indexArticle(articleID int) error {
+	var (
+		userID                  int
+		title, excerpt, content sql.NullString
+		tags                    sql.NullString
+	)
+	err := DB.QueryRow(`
+		SELECT a.user_id, a.title, a.excerpt, a.content,
+			(SELECT group_concat(t.name, ' ')
+			 FROM tags t
+			 JOIN article_tags at ON t.id = at.tag_id
+			 WHERE at.article_id = a.id)
+		FROM articles a
+		WHERE a.id = ?`, articleID).Scan(&userID, &title, &excerpt, &content, &tags)
+	if err == sql.ErrNoRows {
+		return removeArticleFromIndex(articleID)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM articles_fts WHERE rowid = ?", articleID); err != nil {
+		return err
+	}
+
+	var terms []string
+	fields := []*sql.NullString{&title, &excerpt, &content, &tags}
+	for _, field := range fields {
+		var subWords []string
+		field.String, subWords = segmentForIndex(field.String)
+		terms = append(terms, subWords...)
+	}
+
+	_, err = tx.Exec("INSERT INTO articles_fts(rowid, title, excerpt, content, tags, terms, user_id) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		articleID, title.String, excerpt.String, content.String, tags.String, strings.Join(terms, " "), userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// removeArticleFromIndex deletes the full-text search entry of an article.
+func removeArticleFromIndex(articleID int) error {
+	_, err := DB.Exec("DELETE FROM articles_fts WHERE rowid = ?", articleID)
+	return err
+}
+
+// backfillSearchIndex indexes articles that are missing from the full-text
+// search index, such as those saved before the index existed.
+func backfillSearchIndex() error {
+	rows, err := DB.Query("SELECT id FROM articles WHERE id NOT IN (SELECT rowid FROM articles_fts)")
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := indexArticle(id); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) > 0 {
+		log.Printf("Indexed %d articles for full-text search", len(ids))
+	}
+
+	return nil
+}
+
+// buildMatchQuery turns free-form user input into an FTS5 MATCH expression.
+// Every term becomes a quoted phrase so that FTS5 syntax characters in the
+// input are matched literally, Chinese terms are segmented the same way as the
+// indexed text, and the last term is matched as a prefix so results update
+// while typing. It returns "" if the input has no searchable terms.
+func buildMatchQuery(query string) string {
+	var phrases []string
+	for _, term := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		phrases = append(phrases, `"`+strings.Join(segment.Cut(term), " ")+`"`)
+	}
+	if len(phrases) == 0 {
+		return ""
+	}
+
+	phrases[len(phrases)-1] += "*"
+
+	return strings.Join(phrases, " AND ")
+}
@@ -2,8 +2,15 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"math"
+	"net/url"
 	"read-it-later/backend/model"
+	"read-it-later/backend/segment"
+	"strings"
+	"time"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 )
@@ -13,7 +20,9 @@ var DB *sql.DB
 // InitDB initializes the SQLite database and creates tables if they don't exist.
 func InitDB(dataSourceName string) {
 	var err error
-	DB, err = sql.Open("sqlite", dataSourceName)
+	// Enable foreign keys so ON DELETE CASCADE cleans up dependent rows, and
+	// WAL with a busy timeout so background workers can write concurrently
+	DB, err = sql.Open("sqlite", dataSourceName+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 	}
@@ -23,6 +32,10 @@ func InitDB(dataSourceName string) {
 	}
 
 	createTables()
+
+	if err := backfillSearchIndex(); err != nil {
+		log.Fatalf("Error building search index: %v", err)
+	}
 }
 
 // createTables creates the necessary tables in the database.
@@ -47,7 +60,16 @@ func createTables() {
 		content TEXT,
 		excerpt TEXT,
 		image_url TEXT,
+		domain TEXT NOT NULL DEFAULT '',
+		reading_time INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'ready',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		is_read BOOLEAN NOT NULL DEFAULT 0,
+		read_at TIMESTAMP,
+		is_archived BOOLEAN NOT NULL DEFAULT 0,
+		archived_at TIMESTAMP,
+		is_favorite BOOLEAN NOT NULL DEFAULT 0,
+		favorited_at TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 		UNIQUE(user_id, url)
 	);`
@@ -70,6 +92,182 @@ func createTables() {
 		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
 	);`
 
+	// 阅读进度表，每篇文章一条记录
+	readingProgressTable := `
+	CREATE TABLE IF NOT EXISTS reading_progress (
+		article_id INTEGER PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		percentage REAL NOT NULL DEFAULT 0,
+		char_offset INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	// 高亮与批注表，使用字符偏移量和引文选择器定位
+	highlightsTable := `
+	CREATE TABLE IF NOT EXISTS highlights (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		article_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		quote TEXT NOT NULL,
+		prefix TEXT NOT NULL DEFAULT '',
+		suffix TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		color TEXT NOT NULL DEFAULT 'yellow',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	// 全文搜索索引，rowid 与文章 ID 一致；中文在写入前已分词，terms 列保存长词中的子词
+	articlesFTSTable := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS articles_fts USING fts5(
+		title,
+		excerpt,
+		content,
+		tags,
+		terms,
+		user_id UNINDEXED,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);`
+
+	// 后台任务表，例如异步提取文章内容
+	jobsTable := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		article_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 3,
+		last_error TEXT NOT NULL DEFAULT '',
+		run_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+	);`
+
+	// 文章的本地资源（图片等），文件按内容哈希存放在磁盘上
+	articleAssetsTable := `
+	CREATE TABLE IF NOT EXISTS article_assets (
+		article_id INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		media_type TEXT NOT NULL,
+		encoding TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL,
+		source_url TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (article_id, kind, hash),
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+	);`
+
+	// 文章 WARC 归档中记录的索引，offset 和 length 指向压缩后的记录
+	warcRecordsTable := `
+	CREATE TABLE IF NOT EXISTS warc_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		article_id INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		record_id TEXT NOT NULL,
+		record_type TEXT NOT NULL,
+		target_uri TEXT NOT NULL DEFAULT '',
+		status INTEGER NOT NULL DEFAULT 0,
+		media_type TEXT NOT NULL DEFAULT '',
+		payload_digest TEXT NOT NULL DEFAULT '',
+		offset INTEGER NOT NULL,
+		length INTEGER NOT NULL,
+		recorded_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+	);`
+
+	// 从其他服务导入文章的任务，以及其中每篇文章的导入结果
+	importsTable := `
+	CREATE TABLE IF NOT EXISTS imports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'running',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	importItemsTable := `
+	CREATE TABLE IF NOT EXISTS import_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		import_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '[]',
+		saved_at TIMESTAMP,
+		is_read BOOLEAN NOT NULL DEFAULT 0,
+		is_archived BOOLEAN NOT NULL DEFAULT 0,
+		is_favorite BOOLEAN NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT NOT NULL DEFAULT '',
+		article_id INTEGER,
+		data TEXT,
+		FOREIGN KEY (import_id) REFERENCES imports(id) ON DELETE CASCADE,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE SET NULL
+	);`
+
+	// 订阅的 RSS/Atom/JSON Feed，以及见过的条目（按 GUID 去重）
+	feedsTable := `
+	CREATE TABLE IF NOT EXISTS feeds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		site_url TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '[]',
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		last_polled_at TIMESTAMP,
+		next_poll_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE(user_id, url)
+	);`
+
+	feedEntriesTable := `
+	CREATE TABLE IF NOT EXISTS feed_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_id INTEGER NOT NULL,
+		guid TEXT NOT NULL,
+		url TEXT NOT NULL DEFAULT '',
+		title TEXT NOT NULL DEFAULT '',
+		published_at TIMESTAMP,
+		article_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+		FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE SET NULL,
+		UNIQUE(feed_id, guid)
+	);`
+
+	// 发布的文章订阅源，通过 URL 中的随机令牌访问
+	publishedFeedsTable := `
+	CREATE TABLE IF NOT EXISTS published_feeds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		tag_id INTEGER,
+		state TEXT NOT NULL DEFAULT '',
+		token TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		token_rotated_at TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);`
+
 	// 执行表创建
 	_, err := DB.Exec(usersTable)
 	if err != nil {
@@ -90,17 +288,286 @@ func createTables() {
 	if err != nil {
 		log.Fatalf("Error creating article_tags table: %v", err)
 	}
+
+	_, err = DB.Exec(readingProgressTable)
+	if err != nil {
+		log.Fatalf("Error creating reading_progress table: %v", err)
+	}
+
+	_, err = DB.Exec(highlightsTable)
+	if err != nil {
+		log.Fatalf("Error creating highlights table: %v", err)
+	}
+
+	_, err = DB.Exec(jobsTable)
+	if err != nil {
+		log.Fatalf("Error creating jobs table: %v", err)
+	}
+
+	_, err = DB.Exec(articleAssetsTable)
+	if err != nil {
+		log.Fatalf("Error creating article_assets table: %v", err)
+	}
+
+	_, err = DB.Exec(warcRecordsTable)
+	if err != nil {
+		log.Fatalf("Error creating warc_records table: %v", err)
+	}
+
+	_, err = DB.Exec(importsTable)
+	if err != nil {
+		log.Fatalf("Error creating imports table: %v", err)
+	}
+
+	_, err = DB.Exec(importItemsTable)
+	if err != nil {
+		log.Fatalf("Error creating import_items table: %v", err)
+	}
+
+	_, err = DB.Exec(feedsTable)
+	if err != nil {
+		log.Fatalf("Error creating feeds table: %v", err)
+	}
+
+	_, err = DB.Exec(feedEntriesTable)
+	if err != nil {
+		log.Fatalf("Error creating feed_entries table: %v", err)
+	}
+
+	_, err = DB.Exec(publishedFeedsTable)
+	if err != nil {
+		log.Fatalf("Error creating published_feeds table: %v", err)
+	}
+
+	// 旧版本的索引没有分词，删除后由 backfillSearchIndex 重建
+	hasTerms, err := tableHasColumn("articles_fts", "terms")
+	if err != nil {
+		log.Fatalf("Error inspecting articles_fts table: %v", err)
+	}
+	if !hasTerms {
+		if _, err = DB.Exec("DROP TABLE IF EXISTS articles_fts"); err != nil {
+			log.Fatalf("Error dropping outdated articles_fts table: %v", err)
+		}
+	}
+
+	_, err = DB.Exec(articlesFTSTable)
+	if err != nil {
+		log.Fatalf("Error creating articles_fts table: %v", err)
+	}
+
+	migrateTables()
+}
+
+// migrateTables adds columns introduced after the initial schema to
+// databases created by older versions.
+func migrateTables() {
+	articleColumns := []struct {
+		name       string
+		definition string
+	}{
+		{"is_read", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"read_at", "TIMESTAMP"},
+		{"is_archived", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"archived_at", "TIMESTAMP"},
+		{"is_favorite", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"favorited_at", "TIMESTAMP"},
+		{"domain", "TEXT NOT NULL DEFAULT ''"},
+		{"reading_time", "INTEGER NOT NULL DEFAULT 0"},
+		{"status", "TEXT NOT NULL DEFAULT 'ready'"},
+		{"refreshed_at", "TIMESTAMP"},
+		{"content_html", "TEXT NOT NULL DEFAULT ''"},
+		{"markdown", "TEXT NOT NULL DEFAULT ''"},
+	}
+
+	for _, column := range articleColumns {
+		if err := addColumnIfMissing("articles", column.name, column.definition); err != nil {
+			log.Fatalf("Error migrating articles table: %v", err)
+		}
+	}
+
+	if err := addColumnIfMissing("article_assets", "encoding", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Fatalf("Error migrating article_assets table: %v", err)
+	}
+
+	if err := addColumnIfMissing("import_items", "data", "TEXT"); err != nil {
+		log.Fatalf("Error migrating import_items table: %v", err)
+	}
+
+	// 电子书阅读器访问 OPDS 目录使用的长期令牌，可随时更换或撤销
+	if err := addColumnIfMissing("users", "catalog_token", "TEXT"); err != nil {
+		log.Fatalf("Error migrating users table: %v", err)
+	}
+
+	if err := backfillArticleMetadata(); err != nil {
+		log.Fatalf("Error migrating articles table: %v", err)
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_articles_user_created ON articles(user_id, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_articles_user_domain ON articles(user_id, domain)",
+		"CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs(status, run_at)",
+		"CREATE INDEX IF NOT EXISTS idx_jobs_article ON jobs(article_id, status)",
+		"CREATE INDEX IF NOT EXISTS idx_article_assets_hash ON article_assets(hash)",
+		"CREATE INDEX IF NOT EXISTS idx_warc_records_article ON warc_records(article_id, offset)",
+		"CREATE INDEX IF NOT EXISTS idx_warc_records_target ON warc_records(target_uri)",
+		"CREATE INDEX IF NOT EXISTS idx_import_items_import ON import_items(import_id, status)",
+		"CREATE INDEX IF NOT EXISTS idx_feeds_next_poll ON feeds(next_poll_at)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_catalog_token ON users(catalog_token)",
+	}
+	for _, index := range indexes {
+		if _, err := DB.Exec(index); err != nil {
+			log.Fatalf("Error creating index: %v", err)
+		}
+	}
+}
+
+// backfillArticleMetadata fills in the domain and reading time of articles
+// saved before those columns existed.
+func backfillArticleMetadata() error {
+	rows, err := DB.Query("SELECT id, url, content FROM articles WHERE domain = ''")
+	if err != nil {
+		return err
+	}
+
+	type metadata struct {
+		id          int
+		domain      string
+		readingTime int
+	}
+	var updates []metadata
+	for rows.Next() {
+		var id int
+		var articleURL string
+		var content sql.NullString
+		if err := rows.Scan(&id, &articleURL, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		updates = append(updates, metadata{id, articleDomain(articleURL), estimateReadingTime(content.String)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		if update.domain == "" {
+			continue
+		}
+		if _, err := DB.Exec("UPDATE articles SET domain = ?, reading_time = ? WHERE id = ?", update.domain, update.readingTime, update.id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// articleDomain returns the host name of an article URL without a leading "www.".
+func articleDomain(articleURL string) string {
+	parsedURL, err := url.Parse(articleURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsedURL.Hostname()), "www.")
+}
+
+// estimateReadingTime estimates the minutes needed to read content, assuming
+// 200 words per minute for English and 400 characters per minute for Chinese.
+func estimateReadingTime(content string) int {
+	hanCount, wordCount := 0, 0
+	inWord := false
+	for _, r := range content {
+		switch {
+		case segment.IsHan(r):
+			hanCount++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				wordCount++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+
+	minutes := float64(wordCount)/200 + float64(hanCount)/400
+	if minutes == 0 {
+		return 0
+	}
+	return int(math.Ceil(minutes))
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists.
+func addColumnIfMissing(table, column, definition string) error {
+	exists, err := tableHasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// tableHasColumn reports whether a table has the given column. It reports
+// false if the table does not exist.
+func tableHasColumn(table, column string) (bool, error) {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    bool
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
 // SaveArticle inserts a new article into the database and returns it with the new ID.
 func SaveArticle(article model.Article) (model.Article, error) {
-	stmt, err := DB.Prepare("INSERT INTO articles(user_id, url, title, content, excerpt, image_url) VALUES(?, ?, ?, ?, ?, ?)")
+	article, err := insertArticle(DB, article)
 	if err != nil {
 		return model.Article{}, err
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(article.UserID, article.URL, article.Title, article.Content, article.Excerpt, article.ImageURL)
+	if err := indexArticle(article.ID); err != nil {
+		log.Printf("Error indexing article %d: %v", article.ID, err)
+	}
+
+	return article, nil
+}
+
+// insertArticle inserts an article with its derived domain and reading time.
+// Articles without a status are saved as ready.
+func insertArticle(db execer, article model.Article) (model.Article, error) {
+	article.Domain = articleDomain(article.URL)
+	article.ReadingTime = estimateReadingTime(article.Content)
+	if article.Status == "" {
+		article.Status = model.ArticleReady
+	}
+
+	res, err := db.Exec("INSERT INTO articles(user_id, url, title, content, content_html, markdown, excerpt, image_url, domain, reading_time, status) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		article.UserID, article.URL, article.Title, article.Content, article.ContentHTML, article.Markdown, article.Excerpt, article.ImageURL, article.Domain, article.ReadingTime, article.Status)
 	if err != nil {
 		return model.Article{}, err
 	}
@@ -115,41 +582,97 @@ func SaveArticle(article model.Article) (model.Article, error) {
 	return article, nil
 }
 
-// GetAllArticles retrieves all articles from the database for a specific user.
-func GetAllArticles(userID int) ([]model.Article, error) {
-	rows, err := DB.Query("SELECT id, user_id, url, title, excerpt, image_url, created_at FROM articles WHERE user_id = ? ORDER BY created_at DESC", userID)
+// UpdateExtractedArticle replaces the extracted title, content, excerpt and
+// image of an article and marks it as ready.
+func UpdateExtractedArticle(id int, extracted model.Article) error {
+	_, err := DB.Exec(`
+		UPDATE articles
+		SET title = ?, content = ?, content_html = ?, markdown = ?, excerpt = ?, image_url = ?, reading_time = ?, status = ?
+		WHERE id = ?`,
+		extracted.Title, extracted.Content, extracted.ContentHTML, extracted.Markdown, extracted.Excerpt, extracted.ImageURL,
+		estimateReadingTime(extracted.Content), model.ArticleReady, id)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var articles []model.Article
-	for rows.Next() {
-		var article model.Article
-		if err := rows.Scan(&article.ID, &article.UserID, &article.URL, &article.Title, &article.Excerpt, &article.ImageURL, &article.CreatedAt); err != nil {
-			return nil, err
-		}
+	if err := indexArticle(id); err != nil {
+		log.Printf("Error indexing article %d: %v", id, err)
+	}
 
-		// Get tags for this article
-		tags, err := GetTagsForArticle(article.ID)
-		if err != nil {
-			// Log the error but don't fail the entire request
-			log.Printf("Error getting tags for article %d: %v", article.ID, err)
-			tags = []model.Tag{} // Empty slice instead of nil
-		}
-		article.Tags = tags
+	// Restored articles may have highlights waiting for their content
+	if err := reanchorHighlights(id, extracted.Content); err != nil {
+		log.Printf("Error anchoring highlights of article %d: %v", id, err)
+	}
+
+	return nil
+}
+
+// RefreshArticle replaces the content of an article with a newly extracted
+// version and records when it was refreshed. Tags, highlights and reading
+// state are kept; highlights are re-anchored to the new content.
+func RefreshArticle(id int, extracted model.Article) error {
+	_, err := DB.Exec(`
+		UPDATE articles
+		SET title = ?, content = ?, content_html = ?, markdown = ?, excerpt = ?, image_url = ?, reading_time = ?, status = ?,
+			refreshed_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		extracted.Title, extracted.Content, extracted.ContentHTML, extracted.Markdown, extracted.Excerpt, extracted.ImageURL,
+		estimateReadingTime(extracted.Content), model.ArticleReady, id)
+	if err != nil {
+		return err
+	}
+
+	if err := indexArticle(id); err != nil {
+		log.Printf("Error indexing article %d: %v", id, err)
+	}
+
+	if err := reanchorHighlights(id, extracted.Content); err != nil {
+		log.Printf("Error re-anchoring highlights of article %d: %v", id, err)
+	}
+
+	return nil
+}
 
-		articles = append(articles, article)
+// SetArticleImage sets the lead image of an article if its current lead image
+// is one of replaceable, where an empty string stands for no image. It reports
+// whether the image was set.
+func SetArticleImage(id int, imageURL string, replaceable []string) (bool, error) {
+	if len(replaceable) == 0 {
+		return false, nil
 	}
 
-	return articles, nil
+	args := []interface{}{imageURL, id}
+	for _, url := range replaceable {
+		args = append(args, url)
+	}
+	res, err := DB.Exec(`
+		UPDATE articles SET image_url = ?
+		WHERE id = ? AND COALESCE(image_url, '') IN (?`+strings.Repeat(", ?", len(replaceable)-1)+`)`, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetArticleStatus sets the extraction status of an article.
+func SetArticleStatus(id int, status string) error {
+	_, err := DB.Exec("UPDATE articles SET status = ? WHERE id = ?", status, id)
+	return err
 }
 
 // GetArticleByID retrieves a single article by its ID and user ID.
 func GetArticleByID(id int, userID int) (model.Article, error) {
 	var article model.Article
-	err := DB.QueryRow("SELECT id, user_id, url, title, content, excerpt, image_url, created_at FROM articles WHERE id = ? AND user_id = ?", id, userID).
-		Scan(&article.ID, &article.UserID, &article.URL, &article.Title, &article.Content, &article.Excerpt, &article.ImageURL, &article.CreatedAt)
+	err := scanArticle(DB.QueryRow(`
+		SELECT `+articleColumns+`, COALESCE(a.content, ''), a.content_html, a.markdown
+		FROM articles a
+		LEFT JOIN reading_progress p ON p.article_id = a.id
+		WHERE a.id = ? AND a.user_id = ?`, id, userID), &article, &article.Content, &article.ContentHTML, &article.Markdown)
 
 	if err != nil {
 		return model.Article{}, err
@@ -165,8 +688,62 @@ func GetArticleByID(id int, userID int) (model.Article, error) {
 	return article, nil
 }
 
-// DeleteArticleByID deletes an article by its ID and user ID.
+// UpdateArticleState sets the read, archived and favorite flags of an article.
+// Each flag's timestamp is recorded when the flag is turned on and cleared when
+// it is turned off; fields left nil in the update are not touched.
+func UpdateArticleState(id int, userID int, update model.ArticleStateUpdate) (model.Article, error) {
+	var assignments []string
+	var args []interface{}
+
+	flags := []struct {
+		value     *bool
+		column    string
+		timestamp string
+	}{
+		{update.IsRead, "is_read", "read_at"},
+		{update.IsArchived, "is_archived", "archived_at"},
+		{update.IsFavorite, "is_favorite", "favorited_at"},
+	}
+
+	for _, flag := range flags {
+		if flag.value == nil {
+			continue
+		}
+		assignments = append(assignments,
+			flag.column+" = ?",
+			fmt.Sprintf("%s = CASE WHEN ? THEN COALESCE(%s, CURRENT_TIMESTAMP) ELSE NULL END", flag.timestamp, flag.timestamp),
+		)
+		args = append(args, *flag.value, *flag.value)
+	}
+
+	if len(assignments) > 0 {
+		args = append(args, id, userID)
+		result, err := DB.Exec("UPDATE articles SET "+strings.Join(assignments, ", ")+" WHERE id = ? AND user_id = ?", args...)
+		if err != nil {
+			return model.Article{}, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return model.Article{}, err
+		}
+
+		if rowsAffected == 0 {
+			return model.Article{}, sql.ErrNoRows
+		}
+	}
+
+	return GetArticleByID(id, userID)
+}
+
+// DeleteArticleByID deletes an article by its ID and user ID, along with the
+// asset files no other article refers to.
 func DeleteArticleByID(id int, userID int) error {
+	hashes, err := articleAssetHashes(id, "")
+	if err != nil {
+		return err
+	}
+
 	stmt, err := DB.Prepare("DELETE FROM articles WHERE id = ? AND user_id = ?")
 	if err != nil {
 		return err
@@ -187,6 +764,11 @@ func DeleteArticleByID(id int, userID int) error {
 		return sql.ErrNoRows
 	}
 
+	if err := removeArticleFromIndex(id); err != nil {
+		log.Printf("Error removing article %d from search index: %v", id, err)
+	}
+
+	removeOrphanAssets(hashes)
 	return nil
 }
 
@@ -226,58 +808,80 @@ func GetOrCreateTag(tagName string, userID int) (model.Tag, error) {
 	return tag, nil
 }
 
-// AddTagToArticleByID adds a tag to an article.
-func AddTagToArticleByID(articleID int, tagName string, userID int) error {
+// AddTagToArticleByID adds a tag to an article and returns the tag.
+func AddTagToArticleByID(articleID int, tagName string, userID int) (model.Tag, error) {
 	// Check if article exists and belongs to the user
 	var exists bool
 	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM articles WHERE id = ? AND user_id = ?)", articleID, userID).Scan(&exists)
 	if err != nil {
-		return err
+		return model.Tag{}, err
 	}
 	if !exists {
-		return sql.ErrNoRows
+		return model.Tag{}, sql.ErrNoRows
 	}
 
 	// Get or create tag for this user
 	tag, err := GetOrCreateTag(tagName, userID)
 	if err != nil {
-		return err
+		return model.Tag{}, err
 	}
 
 	// Add relationship
 	stmt, err := DB.Prepare("INSERT OR IGNORE INTO article_tags(article_id, tag_id) VALUES(?, ?)")
 	if err != nil {
-		return err
+		return model.Tag{}, err
 	}
 	defer stmt.Close()
 
 	_, err = stmt.Exec(articleID, tag.ID)
-	return err
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	if err := indexArticle(articleID); err != nil {
+		log.Printf("Error indexing article %d: %v", articleID, err)
+	}
+
+	return tag, nil
 }
 
-// RemoveTagFromArticle removes a tag from an article.
-func RemoveTagFromArticle(articleID int, tagID int) error {
-	stmt, err := DB.Prepare("DELETE FROM article_tags WHERE article_id = ? AND tag_id = ?")
+// RemoveTagFromArticle removes a tag from one of the user's articles and
+// returns the removed tag.
+func RemoveTagFromArticle(articleID int, tagID int, userID int) (model.Tag, error) {
+	var tag model.Tag
+	err := DB.QueryRow("SELECT id, name FROM tags WHERE id = ? AND user_id = ?", tagID, userID).Scan(&tag.ID, &tag.Name)
 	if err != nil {
-		return err
+		return model.Tag{}, err
+	}
+
+	stmt, err := DB.Prepare(`
+		DELETE FROM article_tags
+		WHERE article_id = ? AND tag_id = ?
+		AND article_id IN (SELECT id FROM articles WHERE user_id = ?)`)
+	if err != nil {
+		return model.Tag{}, err
 	}
 	defer stmt.Close()
 
-	result, err := stmt.Exec(articleID, tagID)
+	result, err := stmt.Exec(articleID, tagID, userID)
 	if err != nil {
-		return err
+		return model.Tag{}, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return model.Tag{}, err
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return model.Tag{}, sql.ErrNoRows
 	}
 
-	return nil
+	if err := indexArticle(articleID); err != nil {
+		log.Printf("Error indexing article %d: %v", articleID, err)
+	}
+
+	return tag, nil
 }
 
 // GetTagsForArticle retrieves all tags for a specific article.
@@ -304,68 +908,46 @@ func GetTagsForArticle(articleID int) ([]model.Tag, error) {
 	return tags, nil
 }
 
-// SearchArticlesByTitle searches articles by title using LIKE query for a specific user.
-func SearchArticlesByTitle(query string, userID int) ([]model.Article, error) {
-	rows, err := DB.Query("SELECT id, user_id, url, title, excerpt, image_url, created_at FROM articles WHERE title LIKE ? AND user_id = ? ORDER BY created_at DESC", "%"+query+"%", userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var articles []model.Article
-	for rows.Next() {
-		var article model.Article
-		if err := rows.Scan(&article.ID, &article.UserID, &article.URL, &article.Title, &article.Excerpt, &article.ImageURL, &article.CreatedAt); err != nil {
-			return nil, err
-		}
-
-		// Get tags for this article
-		tags, err := GetTagsForArticle(article.ID)
-		if err != nil {
-			log.Printf("Error getting tags for article %d: %v", article.ID, err)
-			tags = []model.Tag{}
-		}
-		article.Tags = tags
-
-		articles = append(articles, article)
-	}
-
-	return articles, nil
+// GetTagByID retrieves one of the user's tags.
+func GetTagByID(tagID int, userID int) (model.Tag, error) {
+	var tag model.Tag
+	err := DB.QueryRow("SELECT id, name FROM tags WHERE id = ? AND user_id = ?", tagID, userID).Scan(&tag.ID, &tag.Name)
+	return tag, err
 }
 
-// SearchArticlesByTag searches articles by tag name for a specific user.
-func SearchArticlesByTag(tagName string, userID int) ([]model.Article, error) {
+// GetTagsForUser retrieves the tags of a user that are on at least one
+// article, by name, with the number of articles carrying each.
+func GetTagsForUser(userID int) ([]model.Tag, error) {
 	rows, err := DB.Query(`
-		SELECT a.id, a.user_id, a.url, a.title, a.excerpt, a.image_url, a.created_at
-		FROM articles a
-		JOIN article_tags at ON a.id = at.article_id
-		JOIN tags t ON at.tag_id = t.id
-		WHERE t.name LIKE ? AND a.user_id = ?
-		ORDER BY a.created_at DESC`, "%"+tagName+"%", userID)
+		SELECT t.id, t.name, COUNT(at.article_id)
+		FROM tags t
+		JOIN article_tags at ON t.id = at.tag_id
+		WHERE t.user_id = ?
+		GROUP BY t.id
+		ORDER BY t.name COLLATE NOCASE`, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var articles []model.Article
+	tags := []model.Tag{}
 	for rows.Next() {
-		var article model.Article
-		if err := rows.Scan(&article.ID, &article.UserID, &article.URL, &article.Title, &article.Excerpt, &article.ImageURL, &article.CreatedAt); err != nil {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.ArticleCount); err != nil {
 			return nil, err
 		}
+		tags = append(tags, tag)
+	}
 
-		// Get tags for this article
-		tags, err := GetTagsForArticle(article.ID)
-		if err != nil {
-			log.Printf("Error getting tags for article %d: %v", article.ID, err)
-			tags = []model.Tag{}
-		}
-		article.Tags = tags
+	return tags, rows.Err()
+}
 
-		articles = append(articles, article)
+// nullTimePtr converts a nullable timestamp column into an optional time.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
 	}
-
-	return articles, nil
+	return &t.Time
 }
 
 // ===== 用户相关数据库操作 =====
@@ -0,0 +1,74 @@
+package store
+
+import "read-it-later/backend/model"
+
+// SetArticleWARC replaces the WARC archive of an article together with the
+// index of its records. The previous archive is removed from disk unless
+// another article refers to it.
+func SetArticleWARC(articleID int, archive model.Asset, records []model.WARCRecord) error {
+	previous, err := articleAssetHashes(articleID, model.AssetWARC)
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceArticleAssets(tx, articleID, model.AssetWARC, []model.Asset{archive}); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM warc_records WHERE article_id = ?", articleID); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		_, err := tx.Exec(`
+			INSERT INTO warc_records(article_id, hash, record_id, record_type, target_uri, status, media_type,
+				payload_digest, offset, length, recorded_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			articleID, archive.Hash, record.RecordID, record.Type, record.TargetURI, record.Status, record.MediaType,
+			record.PayloadDigest, record.Offset, record.Length, record.RecordedAt.UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	removeOrphanAssets(previous)
+	return nil
+}
+
+// GetWARCRecords retrieves the index of an article's WARC archive, in the
+// order the records are stored in.
+func GetWARCRecords(articleID int) ([]model.WARCRecord, error) {
+	rows, err := DB.Query(`
+		SELECT id, article_id, hash, record_id, record_type, target_uri, status, media_type,
+			payload_digest, offset, length, recorded_at
+		FROM warc_records
+		WHERE article_id = ?
+		ORDER BY offset`, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []model.WARCRecord{}
+	for rows.Next() {
+		var record model.WARCRecord
+		err := rows.Scan(&record.ID, &record.ArticleID, &record.Hash, &record.RecordID, &record.Type, &record.TargetURI,
+			&record.Status, &record.MediaType, &record.PayloadDigest, &record.Offset, &record.Length, &record.RecordedAt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,153 @@
+package warc
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// MaxRecordedSize limits the payload recorded for one response. Larger
+// responses are passed through but left out of the archive.
+const MaxRecordedSize = 50 << 20
+
+// Recorder collects HTTP exchanges to write them to a WARC file. It is safe
+// for concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewRecorder creates an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Add records an exchange.
+func (r *Recorder) Add(exchange Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, exchange)
+}
+
+// Len returns the number of recorded exchanges.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.exchanges)
+}
+
+// Write writes a warcinfo record describing the archive followed by the
+// recorded exchanges, and returns the index entries of the records.
+func (r *Recorder) Write(w io.Writer, software string) ([]Entry, error) {
+	r.mu.Lock()
+	exchanges := append([]Exchange{}, r.exchanges...)
+	r.mu.Unlock()
+
+	writer := NewWriter(w)
+	date := time.Now()
+	info := "software: " + software + "\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"
+	warcinfoID, offset, length, err := writer.WriteRecord(Record{
+		Type:        TypeWarcinfo,
+		Date:        date,
+		ContentType: "application/warc-fields",
+		Block:       []byte(info),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{{
+		RecordID: warcinfoID,
+		Type:     TypeWarcinfo,
+		Date:     date,
+		Offset:   offset,
+		Length:   length,
+	}}
+	for _, exchange := range exchanges {
+		written, err := writer.WriteExchange(exchange, warcinfoID)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, written...)
+	}
+	return entries, nil
+}
+
+// Transport returns a RoundTripper that sends requests with base and records
+// them. Compression is not requested from servers, so response bodies are
+// recorded as sent.
+func (r *Recorder) Transport(base http.RoundTripper) http.RoundTripper {
+	if t, ok := base.(*http.Transport); ok {
+		t = t.Clone()
+		t.DisableCompression = true
+		base = t
+	}
+	return &recordingTransport{recorder: r, base: base}
+}
+
+// recordingTransport is the RoundTripper returned by Recorder.Transport.
+type recordingTransport struct {
+	recorder *Recorder
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	date := time.Now()
+
+	var ipAddress string
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+				ipAddress = addr.IP.String()
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxRecordedSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > MaxRecordedSize {
+		// Too large to archive, pass the rest through unread
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := req.Header.Clone()
+	if header.Get("User-Agent") == "" {
+		header.Set("User-Agent", "Go-http-client/1.1")
+	}
+	t.recorder.Add(Exchange{
+		URL:            req.URL.String(),
+		Date:           date,
+		IPAddress:      ipAddress,
+		Request:        RequestMessage(req.Method, req.URL.String(), header),
+		ResponseHeader: ResponseHeader(resp.StatusCode, statusText(resp), resp.Header),
+		Body:           body,
+	})
+
+	return resp, nil
+}
+
+// statusText returns the reason phrase of a response's status line.
+func statusText(resp *http.Response) string {
+	if len(resp.Status) > 4 {
+		return resp.Status[4:]
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+// Package warc records HTTP traffic and writes it as WARC 1.1 web archives
+// (ISO 28500), which standard tools such as pywb and ReplayWeb.page can
+// replay. Every record is compressed as a separate gzip member, so the files
+// are .warc.gz files whose records can be read from their offset alone.
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record types.
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+)
+
+// Record is a WARC record.
+type Record struct {
+	Type      string
+	TargetURI string
+	Date      time.Time
+	// ContentType is the media type of Block.
+	ContentType string
+	// Headers holds named fields beyond the ones derived from the other
+	// fields, such as WARC-IP-Address.
+	Headers map[string]string
+	Block   []byte
+}
+
+// Entry locates a record in a WARC file, like a line of a CDX index.
+type Entry struct {
+	RecordID  string
+	Type      string
+	TargetURI string
+	Date      time.Time
+	// Status and MediaType describe the HTTP response of response records.
+	Status        int
+	MediaType     string
+	PayloadDigest string
+	// Offset and Length give the position of the compressed record in the file.
+	Offset int64
+	Length int64
+}
+
+// Writer writes records to a WARC file.
+type Writer struct {
+	w      io.Writer
+	offset int64
+}
+
+// NewWriter creates a writer that writes records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteRecord writes a record and returns its ID and where it was written.
+func (w *Writer) WriteRecord(record Record) (id string, offset int64, length int64, err error) {
+	id, err = newRecordID()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var header strings.Builder
+	header.WriteString("WARC/1.1\r\n")
+	writeField(&header, "WARC-Type", record.Type)
+	writeField(&header, "WARC-Record-ID", id)
+	writeField(&header, "WARC-Date", record.Date.UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	if record.TargetURI != "" {
+		writeField(&header, "WARC-Target-URI", record.TargetURI)
+	}
+
+	names := make([]string, 0, len(record.Headers))
+	for name := range record.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeField(&header, name, record.Headers[name])
+	}
+
+	writeField(&header, "WARC-Block-Digest", Digest(record.Block))
+	if record.ContentType != "" {
+		writeField(&header, "Content-Type", record.ContentType)
+	}
+	writeField(&header, "Content-Length", fmt.Sprint(len(record.Block)))
+	header.WriteString("\r\n")
+
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(header.String()))
+	zw.Write(record.Block)
+	zw.Write([]byte("\r\n\r\n"))
+	if err := zw.Close(); err != nil {
+		return "", 0, 0, err
+	}
+
+	offset = w.offset
+	n, err := w.w.Write(b.Bytes())
+	w.offset += int64(n)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return id, offset, int64(n), nil
+}
+
+// writeField writes a named field, replacing line breaks that would end it.
+func writeField(b *strings.Builder, name string, value string) {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	b.WriteString(name + ": " + value + "\r\n")
+}
+
+// newRecordID returns a new random record ID.
+func newRecordID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// Digest returns the SHA-1 digest of data in the form used by WARC files.
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// RequestMessage returns an HTTP request message without a body, as stored in
+// request records.
+func RequestMessage(method string, target string, header http.Header) []byte {
+	path, host := target, ""
+	if scheme, rest, ok := strings.Cut(target, "://"); ok && scheme != "" {
+		host, path, _ = strings.Cut(rest, "/")
+		path = "/" + path
+		if i := strings.IndexByte(path, '#'); i >= 0 {
+			path = path[:i]
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, path)
+	if host != "" && header.Get("Host") == "" {
+		fmt.Fprintf(&b, "Host: %s\r\n", host)
+	}
+	header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// ResponseHeader returns the status line and header fields of an HTTP
+// response, ending with the blank line that precedes its body.
+func ResponseHeader(status int, statusText string, header http.Header) []byte {
+	if statusText == "" {
+		statusText = http.StatusText(status)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", status, statusText)
+	header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// Exchange is an HTTP request together with its response.
+type Exchange struct {
+	URL       string
+	Date      time.Time
+	IPAddress string
+	// Request is the HTTP request message.
+	Request []byte
+	// ResponseHeader is the status line and header fields of the response,
+	// as returned by ResponseHeader, and Body the response payload.
+	ResponseHeader []byte
+	Body           []byte
+}
+
+// WriteExchange writes a response record and the request record it answers.
+// It returns the index entries of both records.
+func (w *Writer) WriteExchange(exchange Exchange, warcinfoID string) ([]Entry, error) {
+	headers := map[string]string{}
+	if warcinfoID != "" {
+		headers["WARC-Warcinfo-ID"] = warcinfoID
+	}
+	if exchange.IPAddress != "" {
+		headers["WARC-IP-Address"] = exchange.IPAddress
+	}
+	payloadDigest := Digest(exchange.Body)
+	headers["WARC-Payload-Digest"] = payloadDigest
+
+	block := append(append([]byte{}, exchange.ResponseHeader...), exchange.Body...)
+	responseID, offset, length, err := w.WriteRecord(Record{
+		Type:        TypeResponse,
+		TargetURI:   exchange.URL,
+		Date:        exchange.Date,
+		ContentType: "application/http;msgtype=response",
+		Headers:     headers,
+		Block:       block,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, mediaType := parseResponseHeader(exchange.ResponseHeader)
+	entries := []Entry{{
+		RecordID:      responseID,
+		Type:          TypeResponse,
+		TargetURI:     exchange.URL,
+		Date:          exchange.Date,
+		Status:        status,
+		MediaType:     mediaType,
+		PayloadDigest: payloadDigest,
+		Offset:        offset,
+		Length:        length,
+	}}
+
+	if exchange.Request != nil {
+		headers := map[string]string{"WARC-Concurrent-To": responseID}
+		if warcinfoID != "" {
+			headers["WARC-Warcinfo-ID"] = warcinfoID
+		}
+		requestID, offset, length, err := w.WriteRecord(Record{
+			Type:        TypeRequest,
+			TargetURI:   exchange.URL,
+			Date:        exchange.Date,
+			ContentType: "application/http;msgtype=request",
+			Headers:     headers,
+			Block:       exchange.Request,
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			RecordID:  requestID,
+			Type:      TypeRequest,
+			TargetURI: exchange.URL,
+			Date:      exchange.Date,
+			Offset:    offset,
+			Length:    length,
+		})
+	}
+
+	return entries, nil
+}
+
+// parseResponseHeader reads the status code and media type of a response
+// header written by ResponseHeader.
+func parseResponseHeader(header []byte) (int, string) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), nil)
+	if err != nil {
+		return 0, ""
+	}
+	resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return resp.StatusCode, mediaType
+}
@@ -3,7 +3,7 @@ import axios from 'axios';
 import ArticleList from './components/ArticleList';
 import SearchBar from './components/SearchBar';
 import AuthModal from './components/AuthModal';
-import { useAuth, api } from './hooks/useAuth';
+import { useAuth, useAssetToken, api, withAssetToken } from './hooks/useAuth';
 import './App.css';
 import './components/AuthModal.css';
 
@@ -21,6 +21,7 @@ function App() {
 
   // 使用认证Hook
   const { user, loading: authLoading, error: authError, isAuthenticated, login, register, logout } = useAuth();
+  const assetToken = useAssetToken(isAuthenticated);
 
   // Fetch articles on component mount
   useEffect(() => {
@@ -264,6 +265,7 @@ function App() {
             onAddTag={handleAddTag}
             onRemoveTag={handleRemoveTag}
             onViewArticle={handleViewArticle}
+            assetToken={assetToken}
           />
 
           {/* 文章详情模态框 */}
@@ -276,11 +278,14 @@ function App() {
                 </div>
                 <div className="article-detail-content">
                   {selectedArticle.image_url && (
-                    <img src={selectedArticle.image_url} alt={selectedArticle.title} className="article-detail-image" />
+                    <img src={withAssetToken(selectedArticle.image_url, assetToken)} alt={selectedArticle.title} className="article-detail-image" />
                   )}
                   <div className="article-content">
-                    {selectedArticle.content ? (
-                      <div dangerouslySetInnerHTML={{ __html: selectedArticle.content }} />
+                    {selectedArticle.content_html ? (
+                      // content_html 已在后端按白名单清洗
+                      <div dangerouslySetInnerHTML={{ __html: withAssetToken(selectedArticle.content_html, assetToken) }} />
+                    ) : selectedArticle.content ? (
+                      <div className="article-text">{selectedArticle.content}</div>
                     ) : (
                       <p>{selectedArticle.excerpt}</p>
                     )}
@@ -1,6 +1,7 @@
 import React, { useState } from 'react';
+import { withAssetToken } from '../hooks/useAuth';
 
-const ArticleList = ({ articles, onDeleteArticle, onAddTag, onRemoveTag, onViewArticle }) => {
+const ArticleList = ({ articles, onDeleteArticle, onAddTag, onRemoveTag, onViewArticle, assetToken }) => {
   const [tagInputs, setTagInputs] = useState({});
 
   // 截断文本函数，超过指定长度显示省略号
@@ -42,7 +43,7 @@ const ArticleList = ({ articles, onDeleteArticle, onAddTag, onRemoveTag, onViewA
     <div className="article-list">
       {articles.map(article => (
         <div key={article.id} className="article-card">
-          {article.image_url && <img src={article.image_url} alt={article.title} />}
+          {article.image_url && <img src={withAssetToken(article.image_url, assetToken)} alt={article.title} />}
           <div className="article-card-content">
             <div className="title-with-tags">
               <h2>{article.title}</h2>
@@ -17,6 +17,13 @@ export const removeToken = () => {
   localStorage.removeItem('auth_token');
 };
 
+// 本地保存的文章图片需要认证，<img> 无法携带请求头，因此在地址后附加资源令牌。
+// 资源令牌只能用于获取图片等资源，且一小时后过期，不会泄露登录 token
+export const withAssetToken = (content, assetToken) => {
+  if (!content || !assetToken) return content;
+  return content.replace(/\/api\/assets\/([0-9a-f]{64})(?![0-9a-f?])/g, `/api/assets/$1?token=${encodeURIComponent(assetToken)}`);
+};
+
 // 检查是否已登录
 export const isAuthenticated = () => {
   const token = getToken();
@@ -54,6 +61,42 @@ const apiRequest = async (url, options = {}) => {
   return response.json();
 };
 
+// 获取资源令牌，并在过期前更换
+export const useAssetToken = (enabled) => {
+  const [assetToken, setAssetToken] = useState(null);
+
+  useEffect(() => {
+    if (!enabled) {
+      setAssetToken(null);
+      return undefined;
+    }
+
+    let timer;
+    let cancelled = false;
+    const refresh = async () => {
+      try {
+        const response = await apiRequest('/api/user/asset-token', { method: 'POST' });
+        if (cancelled) return;
+        setAssetToken(response.token);
+        // 过期前五分钟更换
+        const delay = new Date(response.expires_at).getTime() - Date.now() - 5 * 60 * 1000;
+        timer = setTimeout(refresh, Math.max(delay, 60 * 1000));
+      } catch (err) {
+        console.error('Failed to fetch asset token:', err);
+        if (!cancelled) timer = setTimeout(refresh, 60 * 1000);
+      }
+    };
+    refresh();
+
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [enabled]);
+
+  return assetToken;
+};
+
 // 用户认证Hook
 export const useAuth = () => {
   const [user, setUser] = useState(null);
@@ -140,10 +183,24 @@ export const useAuth = () => {
   };
 };
 
+// 按游标逐页获取分页列表的全部文章
+const fetchAllPages = async (url) => {
+  const articles = [];
+  let cursor = '';
+  do {
+    const separator = url.includes('?') ? '&' : '?';
+    const cursorParam = cursor ? `&cursor=${encodeURIComponent(cursor)}` : '';
+    const page = await apiRequest(`${url}${separator}limit=200${cursorParam}`);
+    articles.push(...(page.articles || []));
+    cursor = page.next_cursor;
+  } while (cursor);
+  return articles;
+};
+
 // API调用工具函数
 export const api = {
   // 文章相关API
-  getArticles: () => apiRequest('/api/articles'),
+  getArticles: () => fetchAllPages('/api/articles'),
   
   getArticle: (id) => apiRequest(`/api/articles/${id}`),
   
@@ -183,7 +240,7 @@ export const api = {
     } else {
       params.append('q', query);
     }
-    return apiRequest(`/api/articles/search?${params}`);
+    return fetchAllPages(`/api/articles/search?${params}`);
   },
 };
 